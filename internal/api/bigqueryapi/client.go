@@ -4,36 +4,38 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/bigquery"
 	"github.com/einride/bigquery-importer-slack/internal/tables"
 	"github.com/slack-go/slack"
 	"go.uber.org/zap"
-	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 )
 
 type JobClient struct {
 	Config         JobConfig
 	BigQueryClient *bigquery.Client
 	Logger         *zap.Logger
-}
 
-func allTableRows() []tables.Row {
-	return []tables.Row{
-		&tables.UsersRow{},
-		&tables.UserGroupsRow{},
-		&tables.ChannelsRow{},
-		&tables.ChannelMembersRow{},
-		&tables.FilesRow{},
-	}
+	mu            sync.Mutex              `wire:"-"`
+	loadBuffers   map[string]*loadBuffer  `wire:"-"`
+	insertBatches map[string]*insertBatch `wire:"-"`
+	inserts       int                     `wire:"-"`
+	insertsDone   *sync.Cond              `wire:"-"`
+	insertErr     error                   `wire:"-"`
+	rowCounts     map[string]int          `wire:"-"`
+
+	// putRowsFunc replaces the streaming inserts of putRows when set, e.g. by tests.
+	putRowsFunc func(context.Context, tables.Row, []bigquery.ValueSaver) error `wire:"-"`
 }
 
 // EnsureTables creates new tables.
-// If a table already exists an error will be returned.
+// Tables that already exist are handled according to the configured ConflictPolicy.
 func (c *JobClient) EnsureTables(ctx context.Context) error {
-	c.Logger.Info("ensuring tables")
-	for _, tableRow := range allTableRows() {
+	c.Logger.Info("ensuring tables", zap.String("conflictPolicy", string(c.Config.ConflictPolicy)))
+	for _, tableRow := range c.Config.ExportedTables() {
 		if err := c.createTable(ctx, tableRow); err != nil {
 			return err
 		}
@@ -41,6 +43,71 @@ func (c *JobClient) EnsureTables(ctx context.Context) error {
 	return nil
 }
 
+// ResumeTables prepares the tables of an interrupted run of the job for writing more rows.
+// Unlike EnsureTables, existing tables are kept regardless of the configured ConflictPolicy, since they hold the rows
+// written before the job was interrupted. Missing tables are created.
+func (c *JobClient) ResumeTables(ctx context.Context) error {
+	c.Logger.Info("resuming tables", zap.String("conflictPolicy", string(c.Config.ConflictPolicy)))
+	for _, tableRow := range c.Config.ExportedTables() {
+		if err := c.resumeTable(ctx, tableRow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush inserts the batched rows when using WriteModeStream, after waiting for the inserts of full batches that are
+// in flight, so that all rows passed to the client are written when it returns.
+// Rows buffered for load jobs can not be written until the job is committed, so flushing fails when using
+// WriteModeLoad.
+func (c *JobClient) Flush(ctx context.Context) error {
+	if c.Config.WriteMode != WriteModeStream {
+		return fmt.Errorf("flush: unsupported write mode: %s", c.Config.WriteMode)
+	}
+	return c.flushBatches(ctx)
+}
+
+// Commit publishes the tables written by the job.
+// When using WriteModeLoad the buffered rows are loaded into their staging tables, and when using WriteModeStream the
+// remaining batched rows are inserted. Staging tables are then published to the tables of the job, so that when using
+// WriteModeLoad no table is written unless all tables were loaded. Conflicts are checked for all tables before any
+// table is published, but the tables are then published one at a time, so that a failure while publishing, e.g. a
+// lost connection, may leave only some tables of the snapshot published.
+func (c *JobClient) Commit(ctx context.Context) error {
+	switch c.Config.WriteMode {
+	case WriteModeLoad:
+		if err := c.flush(ctx); err != nil {
+			return err
+		}
+	case WriteModeStream:
+		if err := c.flushBatches(ctx); err != nil {
+			return err
+		}
+	}
+	if !c.staged() {
+		return nil
+	}
+	// Another job may have written a snapshot since the tables were ensured.
+	if c.Config.ConflictPolicy == ConflictPolicyFail {
+		for _, tableRow := range c.Config.ExportedTables() {
+			_, conflict, target, err := c.conflict(ctx, tableRow)
+			if err != nil {
+				return fmt.Errorf("commit: %w", err)
+			}
+			if conflict {
+				return fmt.Errorf("commit: table already exists: %s", target.FullyQualifiedName())
+			}
+		}
+	}
+	c.Logger.Info("committing tables")
+	for _, tableRow := range c.Config.ExportedTables() {
+		if err := c.publishTable(ctx, tableRow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // PutUsers adds an array of slack.User to the corresponding BigQuery table.
 func (c *JobClient) PutUsers(ctx context.Context, users []slack.User) (err error) {
 	defer func() {
@@ -48,20 +115,7 @@ func (c *JobClient) PutUsers(ctx context.Context, users []slack.User) (err error
 			err = fmt.Errorf("put users: %w", err)
 		}
 	}()
-	if len(users) == 0 {
-		return nil
-	}
-	valueSavers := make([]bigquery.ValueSaver, 0, len(users))
-	for _, user := range users {
-		user := user
-		row := tables.UsersRow{
-			Org: c.Config.Org,
-		}
-		row.UnmarshalSlackUser(&user)
-		valueSavers = append(valueSavers, row.ValueSaver(c.Config.ID))
-	}
-	c.Logger.Debug("inserting users", zap.Int("count", len(valueSavers)))
-	return c.inserter(&tables.UsersRow{}).Put(ctx, valueSavers)
+	return c.put(ctx, &tables.UsersRow{}, tables.NewUsersRows(c.Config.Snapshot(), users))
 }
 
 // PutUserGroups adds an array of slack.UserGroup to the corresponding BigQuery table.
@@ -71,20 +125,7 @@ func (c *JobClient) PutUserGroups(ctx context.Context, usergroups []slack.UserGr
 			err = fmt.Errorf("put usergroups: %w", err)
 		}
 	}()
-	if len(usergroups) == 0 {
-		return nil
-	}
-	valueSavers := make([]bigquery.ValueSaver, 0, len(usergroups))
-	for _, usergroup := range usergroups {
-		usergroup := usergroup
-		row := tables.UserGroupsRow{
-			Org: c.Config.Org,
-		}
-		row.UnmarshalSlackUserGroup(&usergroup)
-		valueSavers = append(valueSavers, row.ValueSaver(c.Config.ID))
-	}
-	c.Logger.Debug("inserting usergroups", zap.Int("count", len(valueSavers)))
-	return c.inserter(&tables.UserGroupsRow{}).Put(ctx, valueSavers)
+	return c.put(ctx, &tables.UserGroupsRow{}, tables.NewUserGroupsRows(c.Config.Snapshot(), usergroups))
 }
 
 // PutChannels adds an array of slack.Channel to the corresponding BigQuery table.
@@ -94,20 +135,7 @@ func (c *JobClient) PutChannels(ctx context.Context, channels []slack.Channel) (
 			err = fmt.Errorf("put channels: %w", err)
 		}
 	}()
-	if len(channels) == 0 {
-		return nil
-	}
-	valueSavers := make([]bigquery.ValueSaver, 0, len(channels))
-	for _, channel := range channels {
-		channel := channel
-		row := tables.ChannelsRow{
-			Org: c.Config.Org,
-		}
-		row.UnmarshallSlackChannel(&channel)
-		valueSavers = append(valueSavers, row.ValueSaver(c.Config.ID))
-	}
-	c.Logger.Debug("inserting channels", zap.Int("count", len(valueSavers)))
-	return c.inserter(&tables.ChannelsRow{}).Put(ctx, valueSavers)
+	return c.put(ctx, &tables.ChannelsRow{}, tables.NewChannelsRows(c.Config.Snapshot(), channels))
 }
 
 // PutChannelMembers adds an array of channel members to the corresponding BigQuery table.
@@ -117,20 +145,7 @@ func (c *JobClient) PutChannelMembers(ctx context.Context, channel *slack.Channe
 			err = fmt.Errorf("put channelmembers: %w", err)
 		}
 	}()
-	if len(members) == 0 {
-		return nil
-	}
-	valueSavers := make([]bigquery.ValueSaver, 0, len(members))
-	for _, member := range members {
-		row := tables.ChannelMembersRow{
-			ChannelID:   channel.ID,
-			ChannelName: channel.Name,
-			Member:      member,
-		}
-		valueSavers = append(valueSavers, row.ValueSaver(c.Config.ID))
-	}
-	c.Logger.Debug("inserting channelmembers", zap.Int("count", len(valueSavers)))
-	return c.inserter(&tables.ChannelMembersRow{}).Put(ctx, valueSavers)
+	return c.put(ctx, &tables.ChannelMembersRow{}, tables.NewChannelMembersRows(c.Config.Snapshot(), channel, members))
 }
 
 // PutFiles adds an array of slack.File to the corresponding BigQuery table.
@@ -140,48 +155,232 @@ func (c *JobClient) PutFiles(ctx context.Context, files []slack.File) (err error
 			err = fmt.Errorf("put files: %w", err)
 		}
 	}()
-	if len(files) == 0 {
-		return nil
-	}
-	valueSavers := make([]bigquery.ValueSaver, 0, len(files))
-	for _, file := range files {
-		file := file
-		row := &tables.FilesRow{}
-		row.UnmarshalFile(&file)
-		valueSavers = append(valueSavers, row.ValueSaver(c.Config.ID))
-	}
-	c.Logger.Debug("inserting files", zap.Int("count", len(valueSavers)))
-	return c.inserter(&tables.FilesRow{}).Put(ctx, valueSavers)
+	return c.put(ctx, &tables.FilesRow{}, tables.NewFilesRows(c.Config.Snapshot(), files))
 }
 
-func (c *JobClient) inserter(row tables.Row) *bigquery.Inserter {
-	tableID := row.TableID(c.Config.Date)
-	if c.Config.AppendIDSuffix {
-		tableID = tableID + "_" + c.Config.ID.String()
+// PutMessages adds an array of slack.Message posted in a channel to the corresponding BigQuery table.
+func (c *JobClient) PutMessages(ctx context.Context, channel *slack.Channel, messages []slack.Message) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put messages: %w", err)
+		}
+	}()
+	return c.put(ctx, &tables.MessagesRow{}, tables.NewMessagesRows(c.Config.Snapshot(), channel, messages))
+}
+
+// PutReactions adds the reactions to an array of slack.Message posted in a channel to the corresponding BigQuery
+// table.
+func (c *JobClient) PutReactions(ctx context.Context, channel *slack.Channel, messages []slack.Message) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put reactions: %w", err)
+		}
+	}()
+	return c.put(ctx, &tables.ReactionsRow{}, tables.NewReactionsRows(c.Config.Snapshot(), channel, messages))
+}
+
+// PutPins adds the items pinned to a channel to the corresponding BigQuery table.
+func (c *JobClient) PutPins(ctx context.Context, channel *slack.Channel, items []tables.PinnedItem) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put pins: %w", err)
+		}
+	}()
+	return c.put(ctx, &tables.PinsRow{}, tables.NewPinsRows(c.Config.Snapshot(), channel, items))
+}
+
+// PutBookmarks adds the bookmarks of a channel to the corresponding BigQuery table.
+func (c *JobClient) PutBookmarks(ctx context.Context, channel *slack.Channel, bookmarks []slack.Bookmark) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put bookmarks: %w", err)
+		}
+	}()
+	return c.put(ctx, &tables.BookmarksRow{}, tables.NewBookmarksRows(c.Config.Snapshot(), channel, bookmarks))
+}
+
+// PutDirectConversation adds a direct conversation and its members to the corresponding BigQuery table.
+func (c *JobClient) PutDirectConversation(
+	ctx context.Context,
+	conversation *slack.Channel,
+	members []string,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put direct conversation: %w", err)
+		}
+	}()
+	return c.put(
+		ctx,
+		&tables.DirectConversationsRow{},
+		tables.NewDirectConversationsRows(c.Config.Snapshot(), conversation, members),
+	)
+}
+
+// PutEmoji adds the custom emoji of the workspace to the corresponding BigQuery table.
+func (c *JobClient) PutEmoji(ctx context.Context, emoji map[string]string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put emoji: %w", err)
+		}
+	}()
+	return c.put(ctx, &tables.EmojiRow{}, tables.NewEmojiRows(c.Config.Snapshot(), emoji))
+}
+
+// PutTeam adds the workspace, its custom profile field definitions and the ID of its enterprise organization to the
+// corresponding BigQuery table.
+func (c *JobClient) PutTeam(
+	ctx context.Context,
+	team *slack.TeamInfo,
+	profile *slack.TeamProfile,
+	enterpriseID string,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put team: %w", err)
+		}
+	}()
+	return c.put(ctx, &tables.TeamRow{}, tables.NewTeamRows(c.Config.Snapshot(), team, profile, enterpriseID))
+}
+
+// PutUserProfileFields adds the custom profile fields of a user, joined to their definitions in the team profile, to
+// the corresponding BigQuery table.
+func (c *JobClient) PutUserProfileFields(
+	ctx context.Context,
+	userID string,
+	profile *slack.UserProfile,
+	teamProfile *slack.TeamProfile,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put user profile fields: %w", err)
+		}
+	}()
+	return c.put(
+		ctx,
+		&tables.UserProfileFieldsRow{},
+		tables.NewUserProfileFieldsRows(c.Config.Snapshot(), userID, profile, teamProfile),
+	)
+}
+
+// PutJobRun adds a record of the job run to the job runs table, which is shared by all jobs.
+// The record is inserted immediately, regardless of the configured WriteMode.
+func (c *JobClient) PutJobRun(ctx context.Context, run *tables.JobRunsRow) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put job run: %w", err)
+		}
+	}()
+	table := c.BigQueryClient.Dataset(c.Config.Dataset).Table(run.TableID(c.Config.Date))
+	exists, err := tableExists(ctx, table)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		c.Logger.Info("creating table", zap.Any("fullyQualifiedName", table.FullyQualifiedName()))
+		if err := table.Create(ctx, run.TableMetadata()); err != nil && !isAlreadyExists(err) {
+			return err
+		}
+	} else if err := c.addMissingFields(ctx, table, run.Schema()); err != nil {
+		return err
 	}
-	return c.BigQueryClient.Dataset(c.Config.Dataset).Table(tableID).Inserter()
+	c.Logger.Debug("inserting "+run.TableName(), zap.String("status", run.Status))
+	return table.Inserter().Put(ctx, run.ValueSaver(c.Config.ID))
 }
 
-func (c *JobClient) createTable(ctx context.Context, row tables.Row) (err error) {
+// LastFilesWindow returns the files window of the latest succeeded run of the org whose window ended before the job
+// date, or nil if there is none.
+func (c *JobClient) LastFilesWindow(ctx context.Context) (_ *tables.FilesWindow, err error) {
 	defer func() {
 		if err != nil {
-			err = fmt.Errorf("recreate table %s: %w", row.TableID(c.Config.Date), err)
+			err = fmt.Errorf("get last files window: %w", err)
 		}
 	}()
-	tableID := row.TableID(c.Config.Date)
-	if c.Config.AppendIDSuffix {
-		tableID = tableID + "_" + c.Config.ID.String()
+	run := &tables.JobRunsRow{}
+	table := c.BigQueryClient.Dataset(c.Config.Dataset).Table(run.TableID(c.Config.Date))
+	exists, err := tableExists(ctx, table)
+	if err != nil {
+		return nil, err
 	}
-	table := c.BigQueryClient.Dataset(c.Config.Dataset).Table(tableID)
-	if _, err = table.Metadata(ctx); err == nil {
-		return fmt.Errorf("table already exists: %s", table.FullyQualifiedName())
+	if !exists {
+		return nil, nil
 	}
+	query := c.BigQueryClient.Query(fmt.Sprintf(
+		"SELECT files_from, files_to, files_refreshed_at FROM `%s.%s.%s` "+
+			"WHERE org = @org AND status = @status AND files_to < @before "+
+			"ORDER BY files_to DESC LIMIT 1",
+		table.ProjectID,
+		table.DatasetID,
+		table.TableID,
+	))
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "org", Value: c.Config.Org},
+		{Name: "status", Value: tables.JobStatusSucceeded},
+		{Name: "before", Value: c.Config.Date.In(time.UTC)},
+	}
+	it, err := query.Read(ctx)
 	if err != nil {
-		var errAPI *googleapi.Error
-		if !errors.As(err, &errAPI) || errAPI.Code != http.StatusNotFound {
-			return err
+		return nil, err
+	}
+	var window tables.FilesWindow
+	if err := it.Next(&window); err != nil {
+		if errors.Is(err, iterator.Done) {
+			return nil, nil
 		}
+		return nil, err
+	}
+	return &window, nil
+}
+
+// RowCounts returns the number of rows written to each table by the job.
+func (c *JobClient) RowCounts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int, len(c.rowCounts))
+	for table, count := range c.rowCounts {
+		counts[table] = count
+	}
+	return counts
+}
+
+func (c *JobClient) countRows(table tables.Row, count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rowCounts == nil {
+		c.rowCounts = make(map[string]int)
+	}
+	c.rowCounts[table.TableName()] += count
+}
+
+// put writes rows to the table of the row type according to the configured WriteMode.
+func (c *JobClient) put(ctx context.Context, table tables.Row, rows []tables.Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	valueSavers := make([]bigquery.ValueSaver, 0, len(rows))
+	for _, row := range rows {
+		valueSavers = append(valueSavers, row.ValueSaver(c.Config.ID))
+	}
+	c.Logger.Debug("inserting "+table.TableName(), zap.Int("count", len(valueSavers)))
+	c.countRows(table, len(valueSavers))
+	switch c.Config.WriteMode {
+	case WriteModeStream:
+		return c.batch(ctx, table, valueSavers)
+	case WriteModeLoad:
+		return c.buffer(table, valueSavers)
+	default:
+		return fmt.Errorf("unknown write mode: %s", c.Config.WriteMode)
+	}
+}
+
+func (c *JobClient) inserter(row tables.Row) *bigquery.Inserter {
+	return c.writeTable(row).Inserter()
+}
+
+// putRows inserts rows into the table of the row type with a streaming insert request.
+func (c *JobClient) putRows(ctx context.Context, row tables.Row, rows []bigquery.ValueSaver) error {
+	if c.putRowsFunc != nil {
+		return c.putRowsFunc(ctx, row, rows)
 	}
-	c.Logger.Info("creating table", zap.Any("fullyQualifiedName", table.FullyQualifiedName()))
-	return table.Create(ctx, row.TableMetadata())
+	return c.inserter(row).Put(ctx, rows)
 }
@@ -1,14 +1,198 @@
 package bigqueryapi
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
 	"cloud.google.com/go/civil"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
 	"github.com/google/uuid"
 )
 
 type JobConfig struct {
-	Dataset        string `required:"true"`
-	Org            string `required:"true"`
-	Date           civil.Date
-	ID             uuid.UUID
-	AppendIDSuffix bool
+	Dataset                 string
+	Org                     string `required:"true"`
+	Date                    civil.Date
+	ID                      uuid.UUID
+	AppendIDSuffix          bool
+	Messages                bool
+	MessagesLookback        time.Duration `default:"24h"`
+	ThreadsLookback         time.Duration
+	ConflictPolicy          ConflictPolicy `default:"fail"`
+	WriteMode               WriteMode      `default:"load"`
+	Partitioning            Partitioning   `default:"none"`
+	PartitionExpiration     time.Duration
+	DirectConversations     bool
+	IncludeArchivedChannels bool
+	Tables                  []string
+	ContinueOnError         bool
+	IncrementalFiles        bool
+	FilesFullRefresh        time.Duration
+	UserProfileFields       bool
+	Emoji                   bool
+	Pins                    bool
+	Bookmarks               bool
+	Team                    bool
+}
+
+// Partitioning determines how the daily snapshots of a table are laid out.
+type Partitioning string
+
+const (
+	// PartitioningNone writes each snapshot into its own date-sharded table, e.g. users_20221017.
+	PartitioningNone Partitioning = "none"
+	// PartitioningIngestion writes each snapshot into the partition of the job date of an ingestion-time
+	// partitioned table with a stable name, e.g. users$20221017.
+	PartitioningIngestion Partitioning = "ingestion"
+	// PartitioningSnapshotDate writes each snapshot into the partition of the job date of a table with a stable
+	// name that is partitioned by the snapshot_date column.
+	PartitioningSnapshotDate Partitioning = "snapshot_date"
+)
+
+// WriteMode determines how rows are written to BigQuery.
+type WriteMode string
+
+const (
+	// WriteModeLoad buffers rows and loads them with one load job per table into staging tables when the job is
+	// committed, and publishes the staging tables only once all of them are loaded.
+	WriteModeLoad WriteMode = "load"
+	// WriteModeStream inserts rows with the streaming API in batches as they are exported.
+	WriteModeStream WriteMode = "stream"
+)
+
+// ConflictPolicy determines how a job handles tables that already exist, e.g. when re-running a job for a date.
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyFail fails the job if a table already exists.
+	ConflictPolicyFail ConflictPolicy = "fail"
+	// ConflictPolicyTruncate replaces the rows of existing tables.
+	ConflictPolicyTruncate ConflictPolicy = "truncate"
+	// ConflictPolicyAppend appends rows to existing tables.
+	ConflictPolicyAppend ConflictPolicy = "append"
+	// ConflictPolicySwap writes rows to staging tables that replace the tables when the job is committed.
+	ConflictPolicySwap ConflictPolicy = "swap"
+)
+
+// Snapshot returns the columns identifying the job and snapshot of the rows exported now.
+func (c *JobConfig) Snapshot() tables.Snapshot {
+	return tables.Snapshot{
+		Org:          c.Org,
+		JobID:        c.ID.String(),
+		SnapshotDate: c.Date,
+		ExportedAt:   time.Now().UTC(),
+	}
+}
+
+// ExportedTables returns a row of each table type exported by the job.
+//
+// Tables lists the names of the tables to export, or of the tables not to export when prefixed with "-", e.g.
+// "users,channels" or "-files". When no table is listed for export, all tables are exported except for the tables
+// that need scopes beyond those of the other tables, which are only exported when opted in, see optedIn.
+func (c *JobConfig) ExportedTables() []tables.Row {
+	included := make(map[string]bool)
+	excluded := make(map[string]bool)
+	for _, name := range c.Tables {
+		name = strings.TrimSpace(name)
+		if strings.HasPrefix(name, "-") {
+			excluded[strings.TrimPrefix(name, "-")] = true
+		} else if name != "" {
+			included[name] = true
+		}
+	}
+	rows := make([]tables.Row, 0, len(tables.AllRows()))
+	for _, row := range tables.AllRows() {
+		switch {
+		case excluded[row.TableName()]:
+			continue
+		case len(included) > 0 && !included[row.TableName()]:
+			continue
+		case len(included) == 0 && !c.optedIn(row):
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// optedIn reports whether a table that is only exported by default when configured is configured for export.
+// Such tables need additional scopes, so that enabling them by default would break existing deployments.
+func (c *JobConfig) optedIn(row tables.Row) bool {
+	switch row.(type) {
+	case *tables.MessagesRow, *tables.ReactionsRow:
+		return c.Messages
+	case *tables.DirectConversationsRow:
+		return c.DirectConversations
+	case *tables.UserProfileFieldsRow:
+		return c.UserProfileFields
+	case *tables.EmojiRow:
+		return c.Emoji
+	case *tables.PinsRow:
+		return c.Pins
+	case *tables.BookmarksRow:
+		return c.Bookmarks
+	case *tables.TeamRow:
+		return c.Team
+	default:
+		return true
+	}
+}
+
+// ExportsTable reports whether the job exports the table with the given name.
+func (c *JobConfig) ExportsTable(tableName string) bool {
+	for _, row := range c.ExportedTables() {
+		if row.TableName() == tableName {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTables returns an error if Tables lists an unknown table.
+func (c *JobConfig) ValidateTables() error {
+	names := make(map[string]bool)
+	for _, row := range tables.AllRows() {
+		names[row.TableName()] = true
+	}
+	for _, name := range c.Tables {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "-")
+		if name != "" && !names[name] {
+			return fmt.Errorf("unknown table: %s", name)
+		}
+	}
+	return nil
+}
+
+// MessagesWindow returns the time window of the messages to export.
+// The window ends at the start of the job date (UTC) and spans MessagesLookback.
+func (c *JobConfig) MessagesWindow() (oldest time.Time, latest time.Time) {
+	latest = c.Date.In(time.UTC)
+	return latest.Add(-c.MessagesLookback), latest
+}
+
+// ThreadsWindow returns the time window of the parent messages whose thread replies posted in the messages window are
+// exported. The window ends with the messages window and spans ThreadsLookback, or the messages window if it is
+// longer.
+func (c *JobConfig) ThreadsWindow() (oldest time.Time, latest time.Time) {
+	oldest, latest = c.MessagesWindow()
+	if c.ThreadsLookback > c.MessagesLookback {
+		oldest = latest.Add(-c.ThreadsLookback)
+	}
+	return oldest, latest
+}
+
+// FilesWindow returns the creation time window of the files to export incrementally, given the window of the last
+// run that exported files, which is nil if there is none.
+// The window ends at the start of the job date (UTC) and starts at the end of the last window. The window is open,
+// exporting all files created before its end, when there is no last window or when the last export of all files is
+// FilesFullRefresh or more before the end of the window.
+func (c *JobConfig) FilesWindow(last *tables.FilesWindow) tables.FilesWindow {
+	to := bigquery.NullTimestamp{Timestamp: c.Date.In(time.UTC), Valid: true}
+	if last == nil || !last.FilesTo.Valid || !last.FilesRefreshedAt.Valid ||
+		c.FilesFullRefresh > 0 && to.Timestamp.Sub(last.FilesRefreshedAt.Timestamp) >= c.FilesFullRefresh {
+		return tables.FilesWindow{FilesTo: to, FilesRefreshedAt: to}
+	}
+	return tables.FilesWindow{FilesFrom: last.FilesTo, FilesTo: to, FilesRefreshedAt: last.FilesRefreshedAt}
 }
@@ -0,0 +1,103 @@
+package bigqueryapi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+)
+
+func TestJobConfig_FilesWindow(t *testing.T) {
+	date := civil.Date{Year: 2022, Month: 10, Day: 17}
+	at := func(d civil.Date) bigquery.NullTimestamp {
+		return bigquery.NullTimestamp{Timestamp: d.In(time.UTC), Valid: true}
+	}
+	to := at(date)
+	for _, tt := range []struct {
+		name        string
+		fullRefresh time.Duration
+		last        *tables.FilesWindow
+		want        tables.FilesWindow
+	}{
+		{
+			name: "first run",
+			want: tables.FilesWindow{FilesTo: to, FilesRefreshedAt: to},
+		},
+		{
+			name: "last run without files window",
+			last: &tables.FilesWindow{},
+			want: tables.FilesWindow{FilesTo: to, FilesRefreshedAt: to},
+		},
+		{
+			name: "follows last run",
+			last: &tables.FilesWindow{FilesTo: at(date.AddDays(-1)), FilesRefreshedAt: at(date.AddDays(-3))},
+			want: tables.FilesWindow{FilesFrom: at(date.AddDays(-1)), FilesTo: to, FilesRefreshedAt: at(date.AddDays(-3))},
+		},
+		{
+			name:        "follows last run before full refresh",
+			fullRefresh: 7 * 24 * time.Hour,
+			last:        &tables.FilesWindow{FilesTo: at(date.AddDays(-1)), FilesRefreshedAt: at(date.AddDays(-6))},
+			want: tables.FilesWindow{
+				FilesFrom:        at(date.AddDays(-1)),
+				FilesTo:          to,
+				FilesRefreshedAt: at(date.AddDays(-6)),
+			},
+		},
+		{
+			name:        "full refresh",
+			fullRefresh: 7 * 24 * time.Hour,
+			last:        &tables.FilesWindow{FilesTo: at(date.AddDays(-1)), FilesRefreshedAt: at(date.AddDays(-7))},
+			want:        tables.FilesWindow{FilesTo: to, FilesRefreshedAt: to},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			config := JobConfig{Date: date, FilesFullRefresh: tt.fullRefresh}
+			if got := config.FilesWindow(tt.last); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobConfig_ExportedTables(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config JobConfig
+		want   []string
+	}{
+		{
+			name: "default",
+			want: []string{"users", "usergroups", "channels", "channel_members", "files"},
+		},
+		{
+			name:   "messages",
+			config: JobConfig{Messages: true},
+			want:   []string{"users", "usergroups", "channels", "channel_members", "files", "messages", "reactions"},
+		},
+		{
+			name:   "messages without reactions",
+			config: JobConfig{Messages: true, Tables: []string{"-reactions"}},
+			want:   []string{"users", "usergroups", "channels", "channel_members", "files", "messages"},
+		},
+		{
+			name:   "listed",
+			config: JobConfig{Tables: []string{"users", "reactions"}},
+			want:   []string{"users", "reactions"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, row := range tt.config.ExportedTables() {
+				got = append(got, row.TableName())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
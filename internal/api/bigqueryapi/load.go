@@ -0,0 +1,117 @@
+package bigqueryapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"go.uber.org/zap"
+)
+
+// loadBuffer buffers the rows of a table as newline-delimited JSON in a temporary file until they are loaded.
+type loadBuffer struct {
+	row  tables.Row
+	file *os.File
+	rows int
+}
+
+// buffer appends rows to the load buffer of the table of the row type.
+func (c *JobClient) buffer(row tables.Row, valueSavers []bigquery.ValueSaver) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("buffer rows: %w", err)
+		}
+	}()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tableID := c.tableID(row)
+	if c.loadBuffers == nil {
+		c.loadBuffers = make(map[string]*loadBuffer)
+	}
+	buffer, ok := c.loadBuffers[tableID]
+	if !ok {
+		file, err := os.CreateTemp("", tableID+"-*.ndjson")
+		if err != nil {
+			return err
+		}
+		buffer = &loadBuffer{row: row, file: file}
+		c.loadBuffers[tableID] = buffer
+	}
+	encoder := json.NewEncoder(buffer.file)
+	for _, valueSaver := range valueSavers {
+		values, _, err := valueSaver.Save()
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(values); err != nil {
+			return err
+		}
+		buffer.rows++
+	}
+	return nil
+}
+
+// flush loads all buffered rows into their staging tables, issuing one load job per table.
+func (c *JobClient) flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, buffer := range c.loadBuffers {
+		if err := c.load(ctx, buffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *JobClient) load(ctx context.Context, buffer *loadBuffer) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("load table %s: %w", buffer.row.TableID(c.Config.Date), err)
+		}
+	}()
+	if _, err := buffer.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	table := c.writeTable(buffer.row)
+	source := bigquery.NewReaderSource(buffer.file)
+	source.SourceFormat = bigquery.JSON
+	source.Schema = buffer.row.TableMetadata().Schema
+	loader := table.LoaderFrom(source)
+	loader.CreateDisposition = bigquery.CreateNever
+	loader.WriteDisposition = bigquery.WriteTruncate
+	c.Logger.Info(
+		"loading table",
+		zap.Any("fullyQualifiedName", table.FullyQualifiedName()),
+		zap.Int("count", buffer.rows),
+	)
+	job, err := loader.Run(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
+
+// Close removes the load buffers of the job and discards the batched rows that have not been inserted.
+func (c *JobClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for tableID, buffer := range c.loadBuffers {
+		if err := buffer.file.Close(); err != nil {
+			return err
+		}
+		if err := os.Remove(buffer.file.Name()); err != nil {
+			return err
+		}
+		delete(c.loadBuffers, tableID)
+	}
+	c.insertBatches = nil
+	return nil
+}
@@ -0,0 +1,165 @@
+package bigqueryapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxInsertRows is the maximum number of rows in a streaming insert request, as recommended by BigQuery.
+	// Larger requests are accepted but are slower and more likely to be rejected.
+	maxInsertRows = 500
+	// maxInsertBytes is the maximum size of the rows in a streaming insert request, leaving room for the rest of the
+	// request below the 10MB request size limit.
+	maxInsertBytes = 9 << 20
+)
+
+// insertBatch buffers the rows of a table until they are inserted with a single streaming insert request.
+type insertBatch struct {
+	row   tables.Row
+	rows  []bigquery.ValueSaver
+	bytes int
+}
+
+// insertRequestRow is the encoding of a row in the body of a streaming insert request.
+type insertRequestRow struct {
+	InsertID string          `json:"insertId,omitempty"`
+	JSON     json.RawMessage `json:"json"`
+}
+
+// savedRow is a row that has already been saved, so that it is only encoded once.
+type savedRow struct {
+	values   map[string]bigquery.Value
+	insertID string
+}
+
+func (r *savedRow) Save() (map[string]bigquery.Value, string, error) {
+	return r.values, r.insertID, nil
+}
+
+// batch adds rows to the insert batch of the table of the row type, inserting the batch whenever it is full.
+func (c *JobClient) batch(ctx context.Context, row tables.Row, valueSavers []bigquery.ValueSaver) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("batch rows: %w", err)
+		}
+	}()
+	for _, valueSaver := range valueSavers {
+		values, insertID, err := valueSaver.Save()
+		if err != nil {
+			return err
+		}
+		size, err := insertRequestSize(values, insertID)
+		if err != nil {
+			return err
+		}
+		if full := c.addToBatch(row, &savedRow{values: values, insertID: insertID}, size); full != nil {
+			err := c.insert(ctx, full)
+			c.insertDone(err)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// insertRequestSize returns the number of bytes that a row adds to the body of a streaming insert request, including
+// its insert ID and the separator between rows.
+func insertRequestSize(values map[string]bigquery.Value, insertID string) (int, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return 0, err
+	}
+	encoded, err := json.Marshal(insertRequestRow{InsertID: insertID, JSON: data})
+	if err != nil {
+		return 0, err
+	}
+	return len(encoded) + len(","), nil
+}
+
+// addToBatch adds a saved row of size bytes to the insert batch of its table.
+// When the row does not fit in the batch, the full batch is returned for inserting and replaced by a new batch.
+// The insert of the full batch is in flight until insertDone is called.
+func (c *JobClient) addToBatch(row tables.Row, saved *savedRow, size int) *insertBatch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tableID := c.tableID(row)
+	if c.insertBatches == nil {
+		c.insertBatches = make(map[string]*insertBatch)
+	}
+	var full *insertBatch
+	batch, ok := c.insertBatches[tableID]
+	if ok && len(batch.rows) > 0 && (len(batch.rows) >= maxInsertRows || batch.bytes+size > maxInsertBytes) {
+		full = batch
+		ok = false
+		c.inserts++
+	}
+	if !ok {
+		batch = &insertBatch{row: row}
+		c.insertBatches[tableID] = batch
+	}
+	batch.rows = append(batch.rows, saved)
+	batch.bytes += size
+	return full
+}
+
+// insertDone marks the insert of a full batch returned by addToBatch as done, recording its error.
+func (c *JobClient) insertDone(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && c.insertErr == nil {
+		c.insertErr = err
+	}
+	c.inserts--
+	if c.insertsDone != nil {
+		c.insertsDone.Broadcast()
+	}
+}
+
+// flushBatches inserts all batched rows, issuing one streaming insert request per table, after waiting for the
+// inserts of full batches that are in flight. Since rows of other callers may be part of a full batch, progress can
+// not be saved until those inserts succeed, so the error of the first failed insert is returned.
+func (c *JobClient) flushBatches(ctx context.Context) error {
+	c.mu.Lock()
+	if c.insertsDone == nil {
+		c.insertsDone = sync.NewCond(&c.mu)
+	}
+	// The batches are taken in the same critical section, so that no batch becomes full and in flight unseen.
+	for c.inserts > 0 {
+		c.insertsDone.Wait()
+	}
+	batches := c.insertBatches
+	c.insertBatches = nil
+	err := c.insertErr
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, batch := range batches {
+		if err := c.insert(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *JobClient) insert(ctx context.Context, batch *insertBatch) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("insert into table %s: %w", batch.row.TableID(c.Config.Date), err)
+		}
+	}()
+	c.Logger.Debug(
+		"inserting batch of "+batch.row.TableName(),
+		zap.Int("count", len(batch.rows)),
+		zap.Int("bytes", batch.bytes),
+	)
+	return c.putRows(ctx, batch.row, batch.rows)
+}
@@ -0,0 +1,102 @@
+package bigqueryapi
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"go.uber.org/zap"
+)
+
+func TestInsertRequestSize(t *testing.T) {
+	values := map[string]bigquery.Value{"id": "U1", "name": "alice"}
+	size, err := insertRequestSize(values, "insert-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"insertId":"insert-1","json":{"id":"U1","name":"alice"}},`
+	if size != len(want) {
+		t.Errorf("got size %d, want %d", size, len(want))
+	}
+}
+
+func TestJobClient_addToBatch(t *testing.T) {
+	var c JobClient
+	c.Config.Date = civil.Date{Year: 2022, Month: 10, Day: 17}
+	c.Logger = zap.NewNop()
+	row := &tables.UsersRow{}
+	values := map[string]bigquery.Value{"id": "U1"}
+	var full []*insertBatch
+	for i := 0; i < 2*maxInsertRows+1; i++ {
+		if batch := c.addToBatch(row, &savedRow{values: values}, 10); batch != nil {
+			full = append(full, batch)
+		}
+	}
+	if len(full) != 2 {
+		t.Fatalf("got %d full batches, want 2", len(full))
+	}
+	for _, batch := range full {
+		if len(batch.rows) != maxInsertRows {
+			t.Errorf("got %d rows in a full batch, want %d", len(batch.rows), maxInsertRows)
+		}
+	}
+	// A row that does not fit in the remaining bytes of the batch starts a new batch.
+	if batch := c.addToBatch(row, &savedRow{values: values}, maxInsertBytes); batch == nil || len(batch.rows) != 1 {
+		t.Errorf("got full batch %v, want the batch of the remaining row", batch)
+	}
+}
+
+func TestJobClient_Flush_waitsForInserts(t *testing.T) {
+	var c JobClient
+	c.Config.Date = civil.Date{Year: 2022, Month: 10, Day: 17}
+	c.Config.WriteMode = WriteModeStream
+	c.Logger = zap.NewNop()
+	errInsert := errors.New("insert failed")
+	started, release := make(chan struct{}), make(chan struct{})
+	c.putRowsFunc = func(_ context.Context, _ tables.Row, rows []bigquery.ValueSaver) error {
+		if len(rows) < maxInsertRows {
+			t.Error("flushed the remaining rows while the insert of a full batch was in flight")
+			return nil
+		}
+		// The insert of the full batch blocks until released, and fails.
+		close(started)
+		<-release
+		return errInsert
+	}
+	ctx := context.Background()
+	valueSavers := make([]bigquery.ValueSaver, 0, maxInsertRows+1)
+	for i := 0; i < maxInsertRows+1; i++ {
+		valueSavers = append(valueSavers, &savedRow{values: map[string]bigquery.Value{"id": "U1"}})
+	}
+	batched := make(chan error)
+	go func() {
+		batched <- c.batch(ctx, &tables.UsersRow{}, valueSavers)
+	}()
+	<-started
+	flushed := make(chan error)
+	go func() {
+		flushed <- c.Flush(ctx)
+	}()
+	// Flush creates the condition and waits on it in one critical section, so once the condition is observed while
+	// holding the lock, Flush is waiting for the in-flight insert.
+	for {
+		c.mu.Lock()
+		waiting := c.insertsDone != nil
+		c.mu.Unlock()
+		if waiting {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(release)
+	if err := <-batched; !errors.Is(err, errInsert) {
+		t.Fatalf("got batch error %v, want the error of the insert", err)
+	}
+	if err := <-flushed; !errors.Is(err, errInsert) {
+		t.Fatalf("got flush error %v, want the error of the in-flight insert", err)
+	}
+}
@@ -0,0 +1,361 @@
+package bigqueryapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	stagingSuffix     = "_staging"
+	stagingExpiration = 24 * time.Hour
+)
+
+// tableID returns the ID of the table that holds the snapshot of the row type.
+func (c *JobClient) tableID(row tables.Row) string {
+	tableID := row.TableID(c.Config.Date)
+	if c.Config.Partitioning != PartitioningNone {
+		tableID = row.TableName()
+	}
+	if c.Config.AppendIDSuffix {
+		tableID = tableID + "_" + c.Config.ID.String()
+	}
+	return tableID
+}
+
+// table returns the table that holds the snapshot of the row type.
+func (c *JobClient) table(row tables.Row) *bigquery.Table {
+	return c.BigQueryClient.Dataset(c.Config.Dataset).Table(c.tableID(row))
+}
+
+// partition returns the partition of the table of the row type that holds the snapshot of the job date.
+// For tables that are not partitioned the whole table is returned.
+func (c *JobClient) partition(row tables.Row) *bigquery.Table {
+	if c.Config.Partitioning == PartitioningNone {
+		return c.table(row)
+	}
+	return c.BigQueryClient.Dataset(c.Config.Dataset).Table(c.tableID(row) + "$" + tables.PartitionID(c.Config.Date))
+}
+
+// staged reports whether rows are written to staging tables that are published when the job is committed, which is the
+// case when using ConflictPolicySwap or WriteModeLoad.
+func (c *JobClient) staged() bool {
+	return c.Config.ConflictPolicy == ConflictPolicySwap || c.Config.WriteMode == WriteModeLoad
+}
+
+// stagingTable returns the table that rows of the row type are staged in when rows are staged.
+func (c *JobClient) stagingTable(row tables.Row) *bigquery.Table {
+	tableID := row.TableID(c.Config.Date)
+	if c.Config.AppendIDSuffix {
+		tableID = tableID + "_" + c.Config.ID.String()
+	}
+	return c.BigQueryClient.Dataset(c.Config.Dataset).Table(tableID + stagingSuffix)
+}
+
+// writeTable returns the table that rows of the row type are written to during the job.
+// When rows are staged they are written to a staging table until the job is committed.
+func (c *JobClient) writeTable(row tables.Row) *bigquery.Table {
+	if c.staged() {
+		return c.stagingTable(row)
+	}
+	return c.partition(row)
+}
+
+// tableMetadata returns the metadata of the table of the row type, partitioned according to the configuration.
+func (c *JobClient) tableMetadata(row tables.Row) *bigquery.TableMetadata {
+	metadata := row.TableMetadata()
+	switch c.Config.Partitioning {
+	case PartitioningIngestion:
+		metadata.TimePartitioning = &bigquery.TimePartitioning{
+			Type:       bigquery.DayPartitioningType,
+			Expiration: c.Config.PartitionExpiration,
+		}
+	case PartitioningSnapshotDate:
+		metadata.TimePartitioning = &bigquery.TimePartitioning{
+			Type:       bigquery.DayPartitioningType,
+			Field:      "snapshot_date",
+			Expiration: c.Config.PartitionExpiration,
+		}
+	}
+	return metadata
+}
+
+func (c *JobClient) createTable(ctx context.Context, row tables.Row) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("ensure table %s: %w", c.tableID(row), err)
+		}
+	}()
+	table := c.table(row)
+	exists, conflict, target, err := c.conflict(ctx, row)
+	if err != nil {
+		return err
+	}
+	switch c.Config.ConflictPolicy {
+	case ConflictPolicyFail:
+		if conflict {
+			return fmt.Errorf("table already exists: %s", target.FullyQualifiedName())
+		}
+	case ConflictPolicyAppend:
+		if conflict {
+			c.Logger.Info("appending to existing table", zap.Any("fullyQualifiedName", target.FullyQualifiedName()))
+		}
+	case ConflictPolicyTruncate:
+		if conflict {
+			if err := c.truncateTable(ctx, table, target); err != nil {
+				return err
+			}
+		}
+	case ConflictPolicySwap:
+		// The staging table replaces the snapshot when the job is committed.
+	default:
+		return fmt.Errorf("unknown conflict policy: %s", c.Config.ConflictPolicy)
+	}
+	if c.staged() {
+		if err := c.createStagingTable(ctx, row); err != nil {
+			return err
+		}
+	}
+	if exists {
+		// Tables that are kept, e.g. partitioned tables shared by all jobs, may predate columns added since.
+		return c.addMissingFields(ctx, table, row.TableMetadata().Schema)
+	}
+	if c.staged() {
+		// The table is created when the staging table is published, so that no empty table is left if the job fails.
+		return nil
+	}
+	return c.createTargetTable(ctx, row)
+}
+
+// conflict reports whether the table of the row type exists, and whether the target that the snapshot of the job is
+// written to, i.e. the table or its partition of the job date, already has a snapshot.
+func (c *JobClient) conflict(
+	ctx context.Context,
+	row tables.Row,
+) (exists, conflict bool, target *bigquery.Table, err error) {
+	table := c.table(row)
+	if exists, err = tableExists(ctx, table); err != nil {
+		return false, false, nil, err
+	}
+	// A partitioned table is shared by all jobs, so only the partition of the job date can conflict.
+	conflict, target = exists, table
+	if exists && c.Config.Partitioning != PartitioningNone {
+		if conflict, err = c.partitionExists(ctx, row); err != nil {
+			return false, false, nil, err
+		}
+		target = c.partition(row)
+	}
+	return exists, conflict, target, nil
+}
+
+// createTargetTable creates the table of the row type, unless another job created it first.
+func (c *JobClient) createTargetTable(ctx context.Context, row tables.Row) error {
+	table := c.table(row)
+	c.Logger.Info("creating table", zap.Any("fullyQualifiedName", table.FullyQualifiedName()))
+	if err := table.Create(ctx, c.tableMetadata(row)); err != nil && !isAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *JobClient) resumeTable(ctx context.Context, row tables.Row) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("resume table %s: %w", c.tableID(row), err)
+		}
+	}()
+	table, metadata := c.table(row), c.tableMetadata(row)
+	if c.staged() {
+		table, metadata = c.stagingTable(row), row.TableMetadata()
+		metadata.ExpirationTime = time.Now().Add(stagingExpiration)
+	}
+	exists, err := tableExists(ctx, table)
+	if err != nil {
+		return err
+	}
+	if exists {
+		c.Logger.Info("resuming existing table", zap.Any("fullyQualifiedName", table.FullyQualifiedName()))
+		return nil
+	}
+	c.Logger.Info("creating table", zap.Any("fullyQualifiedName", table.FullyQualifiedName()))
+	return table.Create(ctx, metadata)
+}
+
+func (c *JobClient) createStagingTable(ctx context.Context, row tables.Row) error {
+	staging := c.stagingTable(row)
+	exists, err := tableExists(ctx, staging)
+	if err != nil {
+		return err
+	}
+	if exists {
+		c.Logger.Info("deleting stale staging table", zap.Any("fullyQualifiedName", staging.FullyQualifiedName()))
+		if err := staging.Delete(ctx); err != nil {
+			return err
+		}
+	}
+	metadata := row.TableMetadata()
+	metadata.ExpirationTime = time.Now().Add(stagingExpiration)
+	c.Logger.Info("creating staging table", zap.Any("fullyQualifiedName", staging.FullyQualifiedName()))
+	return staging.Create(ctx, metadata)
+}
+
+// truncateTable removes the rows of the target, which is either the table or its partition of the job date.
+// The rows are removed in place rather than by deleting and recreating the table, since BigQuery may drop rows that
+// are streamed into a table shortly after it is recreated with the same name.
+func (c *JobClient) truncateTable(ctx context.Context, table, target *bigquery.Table) error {
+	if c.Config.WriteMode == WriteModeLoad {
+		// The rows of the target are replaced when the staging table is published.
+		c.Logger.Info("replacing existing table on commit", zap.Any("fullyQualifiedName", target.FullyQualifiedName()))
+		return nil
+	}
+	c.Logger.Info("truncating existing table", zap.Any("fullyQualifiedName", target.FullyQualifiedName()))
+	query := c.BigQueryClient.Query(
+		fmt.Sprintf("SELECT * FROM `%s.%s.%s` WHERE FALSE", table.ProjectID, table.DatasetID, table.TableID),
+	)
+	query.Dst = target
+	query.WriteDisposition = bigquery.WriteTruncate
+	query.CreateDisposition = bigquery.CreateNever
+	job, err := query.Run(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
+
+// partitionExists reports whether the partition of the job date in the table of the row type has any rows.
+func (c *JobClient) partitionExists(ctx context.Context, row tables.Row) (bool, error) {
+	query := c.BigQueryClient.Query(fmt.Sprintf(
+		"SELECT total_rows FROM `%s.%s.INFORMATION_SCHEMA.PARTITIONS` "+
+			"WHERE table_name = @table AND partition_id = @partition AND total_rows > 0",
+		c.BigQueryClient.Project(),
+		c.Config.Dataset,
+	))
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "table", Value: c.tableID(row)},
+		{Name: "partition", Value: tables.PartitionID(c.Config.Date)},
+	}
+	it, err := query.Read(ctx)
+	if err != nil {
+		return false, err
+	}
+	var values []bigquery.Value
+	if err := it.Next(&values); err != nil {
+		if errors.Is(err, iterator.Done) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// publishTable atomically replaces the snapshot of the row type with its staging table, or appends the staging table
+// to the snapshot when using ConflictPolicyAppend.
+func (c *JobClient) publishTable(ctx context.Context, row tables.Row) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("publish table %s: %w", c.tableID(row), err)
+		}
+	}()
+	partition, staging := c.partition(row), c.stagingTable(row)
+	exists, err := tableExists(ctx, c.table(row))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := c.createTargetTable(ctx, row); err != nil {
+			return err
+		}
+	}
+	c.Logger.Info(
+		"publishing staging table",
+		zap.Any("fullyQualifiedName", partition.FullyQualifiedName()),
+		zap.Any("staging", staging.FullyQualifiedName()),
+	)
+	// A query job is used rather than a copy job since copy jobs ignore rows in the streaming buffer.
+	query := c.BigQueryClient.Query(
+		fmt.Sprintf("SELECT * FROM `%s.%s.%s`", staging.ProjectID, staging.DatasetID, staging.TableID),
+	)
+	query.Dst = partition
+	query.WriteDisposition = bigquery.WriteTruncate
+	if c.Config.ConflictPolicy == ConflictPolicyAppend {
+		query.WriteDisposition = bigquery.WriteAppend
+	}
+	query.CreateDisposition = bigquery.CreateNever
+	job, err := query.Run(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	if err := status.Err(); err != nil {
+		return err
+	}
+	return staging.Delete(ctx)
+}
+
+// addMissingFields adds the top-level fields of the schema that are missing from the table, e.g. the columns added to
+// a table since it was created. Since BigQuery does not allow adding required columns, the fields are added as
+// nullable.
+func (c *JobClient) addMissingFields(ctx context.Context, table *bigquery.Table, schema bigquery.Schema) error {
+	metadata, err := table.Metadata(ctx)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(metadata.Schema))
+	for _, field := range metadata.Schema {
+		existing[field.Name] = true
+	}
+	update := append(bigquery.Schema(nil), metadata.Schema...)
+	for _, field := range schema {
+		if !existing[field.Name] {
+			update = append(update, nullableField(field))
+		}
+	}
+	if len(update) == len(metadata.Schema) {
+		return nil
+	}
+	c.Logger.Info("adding missing fields to table", zap.Any("fullyQualifiedName", table.FullyQualifiedName()))
+	_, err = table.Update(ctx, bigquery.TableMetadataToUpdate{Schema: update}, metadata.ETag)
+	return err
+}
+
+// nullableField returns a copy of the field and its nested fields that are not required.
+func nullableField(field *bigquery.FieldSchema) *bigquery.FieldSchema {
+	nullable := *field
+	nullable.Required = false
+	nullable.Schema = nil
+	for _, nested := range field.Schema {
+		nullable.Schema = append(nullable.Schema, nullableField(nested))
+	}
+	return &nullable
+}
+
+func tableExists(ctx context.Context, table *bigquery.Table) (bool, error) {
+	if _, err := table.Metadata(ctx); err != nil {
+		var errAPI *googleapi.Error
+		if errors.As(err, &errAPI) && errAPI.Code == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func isAlreadyExists(err error) bool {
+	var errAPI *googleapi.Error
+	return errors.As(err, &errAPI) && errAPI.Code == http.StatusConflict
+}
@@ -3,14 +3,38 @@ package slackapi
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/einride/bigquery-importer-slack/internal/tables"
 	"github.com/slack-go/slack"
 	"go.uber.org/zap"
 )
 
 type SlackClient struct {
+	Config Config
 	Client *slack.Client
 	Logger *zap.Logger
+	// HTTPClient is used for the methods that are called without the slack package. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	mu       sync.Mutex          `wire:"-"`
+	limiters map[string]*limiter `wire:"-"`
+	calls    map[string]int      `wire:"-"`
+}
+
+// APICallCounts returns the number of requests made to each Slack Web API method, including retries.
+func (c *SlackClient) APICallCounts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int, len(c.calls))
+	for method, count := range c.calls {
+		counts[method] = count
+	}
+	return counts
 }
 
 // ListUsers returns all the users in a workspace.
@@ -25,11 +49,26 @@ func (c *SlackClient) ListUsers(
 			err = fmt.Errorf("list users: %v", err)
 		}
 	}()
-	users, err := c.Client.GetUsers()
-	if err != nil {
-		return err
+	pagination := c.Client.GetUsersPaginated()
+	for {
+		if err := c.call(ctx, "users.list", func(ctx context.Context) error {
+			// Only advance the pagination on success, since a failed page marks the pagination as done.
+			next, err := pagination.Next(ctx)
+			if err != nil {
+				return err
+			}
+			pagination = next
+			return nil
+		}); err != nil {
+			if pagination.Done(err) {
+				return nil
+			}
+			return err
+		}
+		if err := put(ctx, pagination.Users); err != nil {
+			return err
+		}
 	}
-	return put(ctx, users)
 }
 
 // ListUserGroups returns all slack.UserGroup's in a workspace.
@@ -44,19 +83,124 @@ func (c *SlackClient) ListUserGroups(
 			err = fmt.Errorf("list usersgroups: %v", err)
 		}
 	}()
-	groups, err := c.Client.GetUserGroups(slack.GetUserGroupsOptionIncludeUsers(true))
-	if err != nil {
+	var groups []slack.UserGroup
+	if err := c.call(ctx, "usergroups.list", func(ctx context.Context) (err error) {
+		groups, err = c.Client.GetUserGroupsContext(ctx, slack.GetUserGroupsOptionIncludeUsers(true))
+		return err
+	}); err != nil {
 		return err
 	}
 	return put(ctx, groups)
 }
 
+// ListEmoji returns the custom emoji of a workspace, mapped from their name to their URL, or to "alias:<name>" for
+// emoji that are aliases for other emoji.
+//
+// Required Scopes: emoji:read.
+func (c *SlackClient) ListEmoji(
+	ctx context.Context,
+	put func(context.Context, map[string]string) error,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("list emoji: %w", err)
+		}
+	}()
+	var emoji map[string]string
+	if err := c.call(ctx, "emoji.list", func(ctx context.Context) (err error) {
+		emoji, err = c.Client.GetEmojiContext(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+	return put(ctx, emoji)
+}
+
+// GetTeamInfo returns the workspace of the bot.
+//
+// Required Scopes: team:read.
+func (c *SlackClient) GetTeamInfo(ctx context.Context) (_ *slack.TeamInfo, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("get team info: %w", err)
+		}
+	}()
+	var team *slack.TeamInfo
+	if err := c.call(ctx, "team.info", func(ctx context.Context) (err error) {
+		team, err = c.Client.GetTeamInfoContext(ctx)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// GetTeamProfile returns the definitions of the custom profile fields of the workspace of the bot.
+//
+// Required Scopes: users.profile:read.
+func (c *SlackClient) GetTeamProfile(ctx context.Context) (_ *slack.TeamProfile, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("get team profile: %w", err)
+		}
+	}()
+	var profile *slack.TeamProfile
+	if err := c.call(ctx, "team.profile.get", func(ctx context.Context) (err error) {
+		profile, err = c.Client.GetTeamProfileContext(ctx)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// GetUserProfile returns the profile of a user, including the values of its custom profile fields.
+//
+// Required Scopes: users.profile:read.
+func (c *SlackClient) GetUserProfile(ctx context.Context, userID string) (_ *slack.UserProfile, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("get user profile %s: %w", userID, err)
+		}
+	}()
+	var profile *slack.UserProfile
+	if err := c.call(ctx, "users.profile.get", func(ctx context.Context) (err error) {
+		profile, err = c.Client.GetUserProfileContext(ctx, &slack.GetUserProfileParameters{
+			UserID:        userID,
+			IncludeLabels: true,
+		})
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// AuthTest returns the identity of the bot, including the IDs of its workspace and enterprise organization.
+func (c *SlackClient) AuthTest(ctx context.Context) (_ *slack.AuthTestResponse, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("auth test: %w", err)
+		}
+	}()
+	var response *slack.AuthTestResponse
+	if err := c.call(ctx, "auth.test", func(ctx context.Context) (err error) {
+		response, err = c.Client.AuthTestContext(ctx)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 // ListChannels returns all public and private channels in a workspace.
 // Only private channels that the slack bot have been added to will be returned.
+// Archived channels are only returned when includeArchived is set.
 //
 // Required scopes: channels:read, groups:read.
 func (c *SlackClient) ListChannels(
 	ctx context.Context,
+	includeArchived bool,
 	put func(context.Context, []slack.Channel) error,
 ) (err error) {
 	defer func() {
@@ -64,18 +208,52 @@ func (c *SlackClient) ListChannels(
 			err = fmt.Errorf("list channels: %v", err)
 		}
 	}()
+	return c.listConversations(
+		ctx,
+		[]string{"public_channel", "private_channel"},
+		!includeArchived,
+		put,
+	)
+}
+
+// ListDirectConversations returns all direct message and multi-party direct message conversations
+// that the slack bot is a member of.
+//
+// Required scopes: im:read, mpim:read.
+func (c *SlackClient) ListDirectConversations(
+	ctx context.Context,
+	put func(context.Context, []slack.Channel) error,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("list direct conversations: %w", err)
+		}
+	}()
+	return c.listConversations(ctx, []string{"im", "mpim"}, false, put)
+}
+
+func (c *SlackClient) listConversations(
+	ctx context.Context,
+	types []string,
+	excludeArchived bool,
+	put func(context.Context, []slack.Channel) error,
+) error {
 	var cursor string
 	for {
-		channels, nextCursor, err := c.Client.GetConversations(&slack.GetConversationsParameters{
-			Cursor:          cursor,
-			ExcludeArchived: true,
-			Types:           []string{"public_channel", "private_channel"},
-		})
-		if err != nil {
+		var channels []slack.Channel
+		var nextCursor string
+		if err := c.call(ctx, "conversations.list", func(ctx context.Context) (err error) {
+			channels, nextCursor, err = c.Client.GetConversationsContext(ctx, &slack.GetConversationsParameters{
+				Cursor:          cursor,
+				ExcludeArchived: excludeArchived,
+				Types:           types,
+			})
+			return err
+		}); err != nil {
 			return err
 		}
 		if err := put(ctx, channels); err != nil {
-			return fmt.Errorf("list channels: %v", err)
+			return err
 		}
 		if nextCursor == "" {
 			break
@@ -98,11 +276,15 @@ func (c *SlackClient) ListChannelMembers(
 	}()
 	var cursor string
 	for {
-		users, nextCursor, err := c.Client.GetUsersInConversation(&slack.GetUsersInConversationParameters{
-			ChannelID: channel.ID,
-			Cursor:    cursor,
-		})
-		if err != nil {
+		var users []string
+		var nextCursor string
+		if err := c.call(ctx, "conversations.members", func(ctx context.Context) (err error) {
+			users, nextCursor, err = c.Client.GetUsersInConversationContext(ctx, &slack.GetUsersInConversationParameters{
+				ChannelID: channel.ID,
+				Cursor:    cursor,
+			})
+			return err
+		}); err != nil {
 			return fmt.Errorf("list channel members: %w", err)
 		}
 		if err = put(ctx, channel, users); err != nil {
@@ -116,26 +298,91 @@ func (c *SlackClient) ListChannelMembers(
 	return nil
 }
 
-// ListFiles returns an array of slack.File.
+// pinsListResponse is the response of pins.list.
+type pinsListResponse struct {
+	Items []tables.PinnedItem `json:"items"`
+	slack.SlackResponse
+}
+
+// ListPins returns the items pinned to a channel.
+// Unlike the slack package, the items include who pinned them and when.
+//
+// Required Scopes: pins:read.
+func (c *SlackClient) ListPins(
+	ctx context.Context,
+	channel *slack.Channel,
+	put func(context.Context, *slack.Channel, []tables.PinnedItem) error,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("list pins: %w", err)
+		}
+	}()
+	var response pinsListResponse
+	if err := c.call(ctx, "pins.list", func(ctx context.Context) error {
+		response = pinsListResponse{}
+		return c.postMethod(ctx, "pins.list", url.Values{"channel": {channel.ID}}, &response)
+	}); err != nil {
+		return err
+	}
+	return put(ctx, channel, response.Items)
+}
+
+// ListBookmarks returns the bookmarks of a channel.
+//
+// Required Scopes: bookmarks:read.
+func (c *SlackClient) ListBookmarks(
+	ctx context.Context,
+	channel *slack.Channel,
+	put func(context.Context, *slack.Channel, []slack.Bookmark) error,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("list bookmarks: %w", err)
+		}
+	}()
+	var bookmarks []slack.Bookmark
+	if err := c.call(ctx, "bookmarks.list", func(ctx context.Context) (err error) {
+		bookmarks, err = c.Client.ListBookmarksContext(ctx, channel.ID)
+		return err
+	}); err != nil {
+		return err
+	}
+	return put(ctx, channel, bookmarks)
+}
+
+// ListFiles returns an array of slack.File created between from (inclusive) and to (exclusive), starting from the
+// page of the cursor. A zero from or to leaves the window open at that end.
+// The cursor of the next page is passed to put along with each page, and is empty for the last page.
 // The bot only has access to files in channels that it has been added to.
 //
 // Required Scopes: files:read.
 func (c *SlackClient) ListFiles(
 	ctx context.Context,
-	put func(context.Context, []slack.File) error,
+	from time.Time,
+	to time.Time,
+	cursor string,
+	put func(ctx context.Context, files []slack.File, nextCursor string) error,
 ) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("list files: %w", err)
 		}
 	}()
-	params := slack.ListFilesParameters{Cursor: ""}
+	if !from.IsZero() || !to.IsZero() {
+		return c.listFilesBetween(ctx, from, to, cursor, put)
+	}
+	params := slack.ListFilesParameters{Cursor: cursor}
 	for {
-		files, newParams, err := c.Client.ListFiles(params)
-		if err != nil {
+		var files []slack.File
+		var newParams *slack.ListFilesParameters
+		if err := c.call(ctx, "files.list", func(ctx context.Context) (err error) {
+			files, newParams, err = c.Client.ListFilesContext(ctx, params)
+			return err
+		}); err != nil {
 			return err
 		}
-		if err = put(ctx, files); err != nil {
+		if err = put(ctx, files, newParams.Cursor); err != nil {
 			return err
 		}
 		if newParams.Cursor == "" {
@@ -145,3 +392,151 @@ func (c *SlackClient) ListFiles(
 	}
 	return nil
 }
+
+// listFilesBetween lists the files created in a time window using page based pagination, since the cursor based
+// pagination of files.list does not filter by creation time. The cursor is the number of the page.
+func (c *SlackClient) listFilesBetween(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+	cursor string,
+	put func(ctx context.Context, files []slack.File, nextCursor string) error,
+) error {
+	params := slack.NewGetFilesParameters()
+	if !from.IsZero() {
+		params.TimestampFrom = slack.JSONTime(from.Unix())
+	}
+	if !to.IsZero() {
+		// ts_to is inclusive, while to is not.
+		params.TimestampTo = slack.JSONTime(to.Unix() - 1)
+	}
+	if cursor != "" {
+		page, err := strconv.Atoi(cursor)
+		if err != nil {
+			return fmt.Errorf("invalid cursor: %s", cursor)
+		}
+		params.Page = page
+	}
+	for {
+		var files []slack.File
+		var paging *slack.Paging
+		if err := c.call(ctx, "files.list", func(ctx context.Context) (err error) {
+			files, paging, err = c.Client.GetFilesContext(ctx, params)
+			return err
+		}); err != nil {
+			return err
+		}
+		var nextCursor string
+		if paging.Page < paging.Pages {
+			nextCursor = strconv.Itoa(paging.Page + 1)
+		}
+		if err := put(ctx, files, nextCursor); err != nil {
+			return err
+		}
+		if nextCursor == "" {
+			break
+		}
+		params.Page = paging.Page + 1
+	}
+	return nil
+}
+
+// ListMessages returns the messages posted in a channel between oldest and latest.
+// The bot only has access to the history of channels that it has been added to.
+//
+// Required Scopes: channels:history, groups:history.
+func (c *SlackClient) ListMessages(
+	ctx context.Context,
+	channel *slack.Channel,
+	oldest time.Time,
+	latest time.Time,
+	put func(context.Context, *slack.Channel, []slack.Message) error,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("list messages: %w", err)
+		}
+	}()
+	params := slack.GetConversationHistoryParameters{
+		ChannelID: channel.ID,
+		Oldest:    formatTimestamp(oldest),
+		Latest:    formatTimestamp(latest),
+	}
+	for {
+		var response *slack.GetConversationHistoryResponse
+		if err := c.call(ctx, "conversations.history", func(ctx context.Context) (err error) {
+			response, err = c.Client.GetConversationHistoryContext(ctx, &params)
+			return err
+		}); err != nil {
+			return err
+		}
+		if err := put(ctx, channel, response.Messages); err != nil {
+			return err
+		}
+		if !response.HasMore || response.ResponseMetaData.NextCursor == "" {
+			break
+		}
+		params.Cursor = response.ResponseMetaData.NextCursor
+	}
+	return nil
+}
+
+// ListThreadReplies returns the replies in the thread started by a parent message, posted between oldest and latest.
+// The parent message itself is not included. Messages without replies are ignored.
+// Replies that were also sent to the channel (thread_broadcast) are not included either, since they are returned by
+// ListMessages.
+//
+// Required Scopes: channels:history, groups:history.
+func (c *SlackClient) ListThreadReplies(
+	ctx context.Context,
+	channel *slack.Channel,
+	parent *slack.Message,
+	oldest time.Time,
+	latest time.Time,
+	put func(context.Context, *slack.Channel, []slack.Message) error,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("list thread replies: %w", err)
+		}
+	}()
+	if parent.ReplyCount == 0 || parent.ThreadTimestamp != parent.Timestamp {
+		return nil
+	}
+	params := slack.GetConversationRepliesParameters{
+		ChannelID: channel.ID,
+		Timestamp: parent.Timestamp,
+		Oldest:    formatTimestamp(oldest),
+		Latest:    formatTimestamp(latest),
+	}
+	for {
+		var messages []slack.Message
+		var hasMore bool
+		var nextCursor string
+		if err := c.call(ctx, "conversations.replies", func(ctx context.Context) (err error) {
+			messages, hasMore, nextCursor, err = c.Client.GetConversationRepliesContext(ctx, &params)
+			return err
+		}); err != nil {
+			return err
+		}
+		replies := make([]slack.Message, 0, len(messages))
+		for _, message := range messages {
+			if message.Timestamp != parent.Timestamp && message.SubType != slack.MsgSubTypeThreadBroadcast {
+				replies = append(replies, message)
+			}
+		}
+		if err := put(ctx, channel, replies); err != nil {
+			return err
+		}
+		if !hasMore || nextCursor == "" {
+			break
+		}
+		params.Cursor = nextCursor
+	}
+	return nil
+}
+
+// formatTimestamp formats t as a Slack message timestamp, e.g. "1355517523.000005".
+func formatTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond))
+}
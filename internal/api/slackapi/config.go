@@ -0,0 +1,19 @@
+package slackapi
+
+import "time"
+
+type Config struct {
+	APIKey         string `json:"-"`
+	APIKeySecret   string
+	APIURL         string
+	MaxRetries     int           `default:"5"`
+	RequestTimeout time.Duration `default:"30s"`
+	// RateLimitWindow is the window that the per-minute rate limits of the tiers apply to, e.g. shorter in tests.
+	RateLimitWindow time.Duration `default:"1m"`
+	// MinBackoff is the initial backoff before retrying a failed request, and the delay before retrying a rate
+	// limited request that does not say when to retry.
+	MinBackoff time.Duration `default:"1s"`
+	MaxBackoff time.Duration `default:"1m"`
+	// Clock defaults to the system clock.
+	Clock Clock `ignored:"true" json:"-"`
+}
@@ -0,0 +1,57 @@
+package slackapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// slackResponse is a response of a Web API method that reports whether the call succeeded.
+type slackResponse interface {
+	Err() error
+}
+
+// postMethod calls a Web API method and decodes its response, for responses with fields that the slack package does
+// not decode. Failed calls return the same errors as the slack package, so that they are retried alike.
+func (c *SlackClient) postMethod(ctx context.Context, method string, values url.Values, response slackResponse) error {
+	apiURL := slack.APIURL
+	if c.Config.APIURL != "" {
+		apiURL = strings.TrimSuffix(c.Config.APIURL, "/") + "/"
+	}
+	values.Set("token", c.Config.APIKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+method, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// Without a valid Retry-After header the request is retried after the minimum backoff.
+		retryAfter := c.Config.MinBackoff
+		if seconds, err := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 64); err == nil && seconds >= 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return &slack.RateLimitedError{RetryAfter: retryAfter}
+	case resp.StatusCode != http.StatusOK:
+		return slack.StatusCodeError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return err
+	}
+	return response.Err()
+}
@@ -0,0 +1,49 @@
+package slackapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestSlackClient_postMethod_rateLimited(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{name: "retry after", retryAfter: "3", want: 3 * time.Second},
+		{name: "missing", want: time.Second},
+		{name: "unparsable", retryAfter: "soon", want: time.Second},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer server.Close()
+			c := &SlackClient{
+				Config:     Config{APIURL: server.URL, MinBackoff: time.Second},
+				HTTPClient: server.Client(),
+			}
+			var response slack.SlackResponse
+			err := c.postMethod(context.Background(), "pins.list", url.Values{}, &response)
+			var rateLimitedErr *slack.RateLimitedError
+			if !errors.As(err, &rateLimitedErr) {
+				t.Fatalf("got error %v, want rate limited error", err)
+			}
+			if rateLimitedErr.RetryAfter != tt.want {
+				t.Errorf("got retry after %v, want %v", rateLimitedErr.RetryAfter, tt.want)
+			}
+		})
+	}
+}
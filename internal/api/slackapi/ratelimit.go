@@ -0,0 +1,221 @@
+package slackapi
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+// Tier is a Slack Web API rate limit tier.
+//
+// See: https://api.slack.com/docs/rate-limits
+type Tier int
+
+const (
+	Tier1 Tier = 1
+	Tier2 Tier = 2
+	Tier3 Tier = 3
+	Tier4 Tier = 4
+)
+
+// methodTiers maps the Slack Web API methods used by the client to their rate limit tier.
+var methodTiers = map[string]Tier{
+	"users.list":            Tier2,
+	"users.profile.get":     Tier4,
+	"usergroups.list":       Tier2,
+	"conversations.list":    Tier2,
+	"conversations.members": Tier4,
+	"conversations.history": Tier3,
+	"conversations.replies": Tier3,
+	"files.list":            Tier3,
+	"emoji.list":            Tier2,
+	"pins.list":             Tier2,
+	"bookmarks.list":        Tier3,
+	"team.info":             Tier3,
+	"team.profile.get":      Tier3,
+	"auth.test":             Tier4,
+}
+
+// RequestsPerMinute returns the number of requests per minute allowed by the tier.
+func (t Tier) RequestsPerMinute() int {
+	switch t {
+	case Tier1:
+		return 1
+	case Tier2:
+		return 20
+	case Tier3:
+		return 50
+	default:
+		return 100
+	}
+}
+
+// tier returns the rate limit tier of a Slack Web API method, defaulting to Tier2 for unknown methods.
+func tier(method string) Tier {
+	if tier, ok := methodTiers[method]; ok {
+		return tier
+	}
+	return Tier2
+}
+
+// Clock tells the time and waits for durations to elapse, so that tests can control the passing of time.
+type Clock interface {
+	Now() time.Time
+	After(time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// limiter spaces out requests to a single Slack Web API method.
+type limiter struct {
+	mu       sync.Mutex
+	clock    Clock
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until a request is allowed or the context is done.
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := l.clock.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	return sleep(ctx, l.clock, at.Sub(now))
+}
+
+// pause holds back all requests for a duration, e.g. when Slack asks to retry after a while.
+func (l *limiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if at := l.clock.Now().Add(d); at.After(l.next) {
+		l.next = at
+	}
+}
+
+func (c *SlackClient) clock() Clock {
+	if c.Config.Clock == nil {
+		return systemClock{}
+	}
+	return c.Config.Clock
+}
+
+func (c *SlackClient) limiter(method string) *limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.limiters == nil {
+		c.limiters = make(map[string]*limiter)
+	}
+	l, ok := c.limiters[method]
+	if !ok {
+		l = &limiter{
+			clock:    c.clock(),
+			interval: c.Config.RateLimitWindow / time.Duration(tier(method).RequestsPerMinute()),
+		}
+		c.limiters[method] = l
+	}
+	return l
+}
+
+func (c *SlackClient) countCall(method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+	}
+	c.calls[method]++
+}
+
+// call invokes the Slack Web API method through fn, honoring the rate limit tier of the method.
+// Each attempt is bounded by the configured request timeout.
+// Rate limited requests are retried after the duration requested by Slack, and transient server
+// and network errors are retried with jittered exponential backoff.
+func (c *SlackClient) call(ctx context.Context, method string, fn func(context.Context) error) error {
+	l := c.limiter(method)
+	backoff := c.Config.MinBackoff
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(ctx); err != nil {
+			return err
+		}
+		c.countCall(method)
+		requestCtx, cancel := context.WithTimeout(ctx, c.Config.RequestTimeout)
+		err := fn(requestCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || attempt >= c.Config.MaxRetries {
+			return err
+		}
+		var rateLimitedErr *slack.RateLimitedError
+		var delay time.Duration
+		switch {
+		case errors.As(err, &rateLimitedErr):
+			delay = rateLimitedErr.RetryAfter
+			l.pause(delay)
+		case isTransient(err):
+			if backoff > 0 {
+				delay = time.Duration(rand.Int63n(int64(backoff))) //nolint:gosec // jitter does not need crypto
+			}
+			if backoff *= 2; backoff > c.Config.MaxBackoff {
+				backoff = c.Config.MaxBackoff
+			}
+		default:
+			return err
+		}
+		c.Logger.Warn(
+			"retrying Slack API request",
+			zap.String("method", method),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+		if err := sleep(ctx, l.clock, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// isTransient reports whether err is a server or network error that is likely to succeed on retry.
+func isTransient(err error) bool {
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(d):
+		return nil
+	}
+}
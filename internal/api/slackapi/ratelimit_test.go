@@ -0,0 +1,272 @@
+package slackapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeClock is a Clock whose time only passes when waiting for a duration to elapse.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestTier(t *testing.T) {
+	for _, tt := range []struct {
+		method            string
+		want              Tier
+		requestsPerMinute int
+	}{
+		{method: "users.list", want: Tier2, requestsPerMinute: 20},
+		{method: "conversations.history", want: Tier3, requestsPerMinute: 50},
+		{method: "conversations.members", want: Tier4, requestsPerMinute: 100},
+		{method: "unknown.method", want: Tier2, requestsPerMinute: 20},
+	} {
+		tt := tt
+		t.Run(tt.method, func(t *testing.T) {
+			got := tier(tt.method)
+			if got != tt.want {
+				t.Errorf("got tier %d, want %d", got, tt.want)
+			}
+			if got.RequestsPerMinute() != tt.requestsPerMinute {
+				t.Errorf("got %d requests per minute, want %d", got.RequestsPerMinute(), tt.requestsPerMinute)
+			}
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		pause  time.Duration
+		waits  int
+		sleeps []time.Duration
+	}{
+		{
+			name:   "spaced",
+			waits:  3,
+			sleeps: []time.Duration{3 * time.Second, 3 * time.Second},
+		},
+		{
+			name:   "paused",
+			pause:  10 * time.Second,
+			waits:  2,
+			sleeps: []time.Duration{10 * time.Second, 3 * time.Second},
+		},
+		{
+			name:   "paused shorter than interval",
+			pause:  time.Second,
+			waits:  2,
+			sleeps: []time.Duration{time.Second, 3 * time.Second},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			l := &limiter{clock: clock, interval: 3 * time.Second}
+			if tt.pause > 0 {
+				l.pause(tt.pause)
+			}
+			for i := 0; i < tt.waits; i++ {
+				if err := l.wait(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assertDurations(t, clock.sleeps, tt.sleeps)
+		})
+	}
+}
+
+func TestLimiter_pause_doesNotShortenWait(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	l := &limiter{clock: clock, interval: 3 * time.Second}
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	l.pause(time.Second)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertDurations(t, clock.sleeps, []time.Duration{3 * time.Second})
+}
+
+func TestSlackClient_call(t *testing.T) {
+	errFatal := errors.New("channel_not_found")
+	errServer := slack.StatusCodeError{Code: http.StatusInternalServerError, Status: "500 Internal Server Error"}
+	errNetwork := &url.Error{Op: "Post", URL: "https://slack.com/api/users.list", Err: errors.New("connection reset")}
+	for _, tt := range []struct {
+		name       string
+		errs       []error
+		maxRetries int
+		wantErr    error
+		wantCalls  int
+		// wantDelays are the delays before each retry, or for jittered delays the exclusive upper bound.
+		wantDelays []time.Duration
+		jittered   bool
+	}{
+		{
+			name:       "success",
+			maxRetries: 3,
+			wantCalls:  1,
+		},
+		{
+			name:       "rate limited",
+			errs:       []error{&slack.RateLimitedError{RetryAfter: 2 * time.Second}},
+			maxRetries: 3,
+			wantCalls:  2,
+			wantDelays: []time.Duration{2 * time.Second},
+		},
+		{
+			name:       "server error",
+			errs:       []error{errServer},
+			maxRetries: 3,
+			wantCalls:  2,
+			wantDelays: []time.Duration{time.Second},
+			jittered:   true,
+		},
+		{
+			name:       "network error",
+			errs:       []error{errNetwork, errNetwork},
+			maxRetries: 3,
+			wantCalls:  3,
+			wantDelays: []time.Duration{time.Second, 2 * time.Second},
+			jittered:   true,
+		},
+		{
+			name:       "exhausted",
+			errs:       []error{errServer, errServer, errServer, errServer, errServer},
+			maxRetries: 3,
+			wantErr:    errServer,
+			wantCalls:  4,
+			wantDelays: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+			jittered:   true,
+		},
+		{
+			name:       "client error",
+			errs:       []error{slack.StatusCodeError{Code: http.StatusNotFound, Status: "404 Not Found"}},
+			maxRetries: 3,
+			wantErr:    slack.StatusCodeError{Code: http.StatusNotFound, Status: "404 Not Found"},
+			wantCalls:  1,
+		},
+		{
+			name:       "fatal",
+			errs:       []error{errFatal},
+			maxRetries: 3,
+			wantErr:    errFatal,
+			wantCalls:  1,
+		},
+		{
+			name:      "no retries",
+			errs:      []error{errServer},
+			wantErr:   errServer,
+			wantCalls: 1,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			c := &SlackClient{
+				Config: Config{
+					MaxRetries:      tt.maxRetries,
+					RequestTimeout:  time.Second,
+					RateLimitWindow: 0,
+					MinBackoff:      time.Second,
+					MaxBackoff:      3 * time.Second,
+					Clock:           &fakeClock{now: time.Unix(0, 0)},
+				},
+				Logger: zap.New(core),
+			}
+			var calls int
+			err := c.call(context.Background(), "users.list", func(context.Context) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", calls, tt.wantCalls)
+			}
+			if got := c.APICallCounts()["users.list"]; got != tt.wantCalls {
+				t.Errorf("got %d counted calls, want %d", got, tt.wantCalls)
+			}
+			var delays []time.Duration
+			for _, entry := range logs.All() {
+				delays = append(delays, entry.ContextMap()["delay"].(time.Duration))
+			}
+			if !tt.jittered {
+				assertDurations(t, delays, tt.wantDelays)
+				return
+			}
+			if len(delays) != len(tt.wantDelays) {
+				t.Fatalf("got delays %v, want %d delays", delays, len(tt.wantDelays))
+			}
+			for i, delay := range delays {
+				if delay < 0 || delay >= tt.wantDelays[i] {
+					t.Errorf("got delay %v before retry %d, want less than %v", delay, i+1, tt.wantDelays[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSlackClient_call_canceled(t *testing.T) {
+	c := &SlackClient{
+		Config: Config{MaxRetries: 3, RequestTimeout: time.Second, Clock: &fakeClock{}},
+		Logger: zap.NewNop(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	err := c.call(ctx, "users.list", func(context.Context) error {
+		calls++
+		cancel()
+		return &slack.RateLimitedError{RetryAfter: time.Second}
+	})
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+}
+
+func assertDurations(t *testing.T, got, want []time.Duration) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
@@ -2,60 +2,310 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+	"cloud.google.com/go/bigquery"
 	"github.com/einride/bigquery-importer-slack/internal/api/slackapi"
+	"github.com/einride/bigquery-importer-slack/internal/checkpoint"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"github.com/einride/bigquery-importer-slack/internal/workerpool"
 	"github.com/slack-go/slack"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 )
 
+// finishJobRunTimeout is the timeout for recording the end of a run.
+const finishJobRunTimeout = 30 * time.Second
+
 type App struct {
-	BigQueryJobClient *bigqueryapi.JobClient
-	SlackClient       *slackapi.SlackClient
-	Logger            *zap.Logger
+	Config      *Config
+	Sink        Sink
+	SlackClient *slackapi.SlackClient
+	Checkpoints *checkpoint.Store
+	Logger      *zap.Logger
+
+	teamProfile *slack.TeamProfile `wire:"-"`
 }
 
 // Run export all the fetched data into its corresponding table.
-func (a *App) Run(ctx context.Context) error {
+// The start and end of the run are recorded in the job runs table.
+//
+// By default Run returns on the first failing export. When the job is configured to continue on errors, all exports
+// are attempted, and their errors are aggregated.
+//
+// When checkpoints are enabled, the progress of the run is saved periodically and when the run is interrupted,
+// and a run of a job with a saved checkpoint skips the work that has already been completed.
+func (a *App) Run(ctx context.Context) (err error) {
 	a.Logger.Info("running")
 	defer a.Logger.Info("stopped")
-	if err := a.BigQueryJobClient.EnsureTables(ctx); err != nil {
+	defer func() {
+		if err := a.Sink.Close(); err != nil {
+			a.Logger.Warn("close sink", zap.Error(err))
+		}
+	}()
+	run := &tables.JobRunsRow{
+		Snapshot:  a.Config.Job.Snapshot(),
+		Status:    tables.JobStatusRunning,
+		StartTime: time.Now().UTC(),
+	}
+	if err := a.Sink.PutJobRun(ctx, run); err != nil {
 		return err
 	}
-	if err := a.exportUsers(ctx); err != nil {
+	defer func() {
+		if errFinish := a.finishJobRun(run, err); errFinish != nil {
+			a.Logger.Error("finish job run", zap.Error(errFinish))
+			if err == nil {
+				err = errFinish
+			}
+		}
+	}()
+	filesWindow, err := a.filesWindow(ctx)
+	if err != nil {
 		return err
 	}
-	if err := a.exportUserGroups(ctx); err != nil {
+	run.FilesWindow = filesWindow
+	ensureTables := a.Sink.EnsureTables
+	if a.Checkpoints.Resuming() {
+		a.Logger.Info("resuming interrupted job from checkpoint")
+		ensureTables = a.Sink.ResumeTables
+	}
+	if err := ensureTables(ctx); err != nil {
+		return err
+	}
+	var committed bool
+	defer func() {
+		if err := a.finishCheckpoints(committed); err != nil {
+			a.Logger.Warn("finish checkpoints", zap.Error(err))
+		}
+	}()
+	stopCheckpoints := a.startCheckpoints(ctx)
+	defer stopCheckpoints()
+	exports := []struct {
+		name   string
+		export func(context.Context) error
+	}{
+		{name: "team", export: a.exportTeam},
+		{name: "users", export: a.exportUsers},
+		{name: "usergroups", export: a.exportUserGroups},
+		{name: "channels", export: a.exportChannels},
+		{name: "direct_conversations", export: a.exportDirectConversations},
+		{name: "files", export: func(ctx context.Context) error {
+			return a.exportFiles(ctx, filesWindow)
+		}},
+		{name: "emoji", export: a.exportEmoji},
+	}
+	var errExports error
+	for _, export := range exports {
+		if a.Checkpoints.Done(checkpointKeyExport + export.name) {
+			a.Logger.Info("skipping completed export", zap.String("export", export.name))
+			continue
+		}
+		if err := export.export(ctx); err != nil {
+			if !a.Config.Job.ContinueOnError || ctx.Err() != nil {
+				return multierr.Append(errExports, err)
+			}
+			a.Logger.Error("export failed, continuing with the next export", zap.Error(err))
+			errExports = multierr.Append(errExports, err)
+			continue
+		}
+		a.Checkpoints.MarkDone(checkpointKeyExport + export.name)
+	}
+	stopCheckpoints()
+	// When continuing on errors the tables are committed also when exports have failed,
+	// and the failed tables are recorded in the job runs table.
+	errCommit := a.Sink.Commit(ctx)
+	committed = errCommit == nil
+	return multierr.Append(errExports, errCommit)
+}
+
+// finishJobRun records the end of the run in the job runs table.
+// The record is written with a fresh context, so that it is written also when the run was cancelled.
+func (a *App) finishJobRun(run *tables.JobRunsRow, errRun error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), finishJobRunTimeout)
+	defer cancel()
+	run.EndTime = bigquery.NullTimestamp{Timestamp: time.Now().UTC(), Valid: true}
+	run.Status = tables.JobStatusSucceeded
+	if errRun != nil {
+		run.Status = tables.JobStatusFailed
+		run.Error = errRun.Error()
+	}
+	run.SlackAPICalls = tables.NewJobRunCounts(a.SlackClient.APICallCounts())
+	run.TableRows = tables.NewJobRunCounts(a.Sink.RowCounts())
+	run.TableErrors = tableErrors(errRun)
+	return a.Sink.PutJobRun(ctx, run)
+}
+
+// filesWindow returns the creation time window of the files to export, which is empty unless files are exported
+// incrementally. The window follows the window of the latest succeeded run, and is recorded in the job runs table.
+func (a *App) filesWindow(ctx context.Context) (tables.FilesWindow, error) {
+	if !a.Config.Job.IncrementalFiles || !a.exports(&tables.FilesRow{}) {
+		return tables.FilesWindow{}, nil
+	}
+	last, err := a.Sink.LastFilesWindow(ctx)
+	if err != nil {
+		return tables.FilesWindow{}, err
+	}
+	window := a.Config.Job.FilesWindow(last)
+	a.Logger.Info(
+		"exporting files incrementally",
+		zap.Bool("fullRefresh", !window.FilesFrom.Valid),
+		zap.Time("from", window.FilesFrom.Timestamp),
+		zap.Time("to", window.FilesTo.Timestamp),
+	)
+	return window, nil
+}
+
+// nonFatal marks the error of a per-channel task as non-fatal when the job is configured to continue on errors,
+// so that the remaining channels are still exported.
+func (a *App) nonFatal(err error) error {
+	if a.Config.Job.ContinueOnError {
+		return workerpool.NonFatal(err)
+	}
+	return err
+}
+
+// exports reports whether the job exports the table of the row type.
+func (a *App) exports(row tables.Row) bool {
+	return a.Config.Job.ExportsTable(row.TableName())
+}
+
+func (a *App) exportTeam(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			err = withTable(&tables.TeamRow{}, fmt.Errorf("export team: %w", err))
+		}
+	}()
+	if !a.exports(&tables.TeamRow{}) {
+		return nil
+	}
+	a.Logger.Info("exporting team")
+	team, err := a.SlackClient.GetTeamInfo(ctx)
+	if err != nil {
 		return err
 	}
-	if err := a.exportChannels(ctx); err != nil {
+	profile, err := a.getTeamProfile(ctx)
+	if err != nil {
 		return err
 	}
-	if err := a.exportFiles(ctx); err != nil {
+	identity, err := a.SlackClient.AuthTest(ctx)
+	if err != nil {
 		return err
 	}
-	return nil
+	return a.Sink.PutTeam(ctx, team, profile, identity.EnterpriseID)
+}
+
+// getTeamProfile returns the definitions of the custom profile fields of the workspace, which are fetched once and
+// shared by the team and user profile fields tables. Since team.profile.get needs the users.profile:read scope, the
+// definitions are only fetched when the user profile fields table is exported, and are nil otherwise.
+// Exports run one at a time, so the definitions are not guarded against concurrent use.
+func (a *App) getTeamProfile(ctx context.Context) (*slack.TeamProfile, error) {
+	if !a.exports(&tables.UserProfileFieldsRow{}) || a.teamProfile != nil {
+		return a.teamProfile, nil
+	}
+	profile, err := a.SlackClient.GetTeamProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.teamProfile = profile
+	return profile, nil
 }
 
 func (a *App) exportUsers(ctx context.Context) (err error) {
+	// Users are listed also when only their custom profile fields are exported.
+	var row tables.Row = &tables.UsersRow{}
+	if !a.exports(row) {
+		row = &tables.UserProfileFieldsRow{}
+	}
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("export users: %w", err)
+			var errTable *tableError
+			if !errors.As(err, &errTable) {
+				err = withTable(row, err)
+			}
 		}
 	}()
+	if !a.exports(row) {
+		return nil
+	}
 	a.Logger.Info("exporting users")
-	return a.SlackClient.ListUsers(ctx, a.BigQueryJobClient.PutUsers)
+	teamProfile, err := a.getTeamProfile(ctx)
+	if err != nil {
+		return withTable(&tables.UserProfileFieldsRow{}, err)
+	}
+	pool, ctx := workerpool.New(ctx, a.Config.Concurrency)
+	errList := a.SlackClient.ListUsers(ctx, func(ctx context.Context, users []slack.User) error {
+		if a.exports(&tables.UsersRow{}) {
+			if err := a.Sink.PutUsers(ctx, users); err != nil {
+				return withTable(&tables.UsersRow{}, err)
+			}
+		}
+		if !a.exports(&tables.UserProfileFieldsRow{}) {
+			return nil
+		}
+		for _, user := range users {
+			user := user
+			if !hasProfileFields(&user) || a.Checkpoints.Done(checkpointKeyUserProfile+user.ID) {
+				continue
+			}
+			pool.Go(func(ctx context.Context) error {
+				if err := a.exportUserProfileFields(ctx, &user, teamProfile); err != nil {
+					return a.nonFatal(err)
+				}
+				a.Checkpoints.MarkDone(checkpointKeyUserProfile + user.ID)
+				return nil
+			})
+		}
+		return nil
+	})
+	if errList != nil {
+		pool.Cancel()
+	}
+	if err := pool.Wait(); err != nil {
+		return err
+	}
+	return errList
+}
+
+// exportUserProfileFields exports the custom profile fields of a user, which users.list does not return.
+// The profile of each user is fetched separately, so the export is limited by the rate limit of users.profile.get.
+func (a *App) exportUserProfileFields(
+	ctx context.Context,
+	user *slack.User,
+	teamProfile *slack.TeamProfile,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = withTable(&tables.UserProfileFieldsRow{}, fmt.Errorf("export user profile fields: %w", err))
+		}
+	}()
+	a.Logger.Debug("exporting user profile fields", zap.String("user", user.ID))
+	profile, err := a.SlackClient.GetUserProfile(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	return a.Sink.PutUserProfileFields(ctx, user.ID, profile, teamProfile)
+}
+
+// hasProfileFields reports whether a user can have custom profile fields.
+// Deleted users, bots and Slackbot are skipped to save calls to users.profile.get.
+func hasProfileFields(user *slack.User) bool {
+	return !user.Deleted && !user.IsBot && user.ID != "USLACKBOT"
 }
 
 func (a *App) exportUserGroups(ctx context.Context) (err error) {
 	defer func() {
 		if err != nil {
-			err = fmt.Errorf("export usersgroups: %w", err)
+			err = withTable(&tables.UserGroupsRow{}, fmt.Errorf("export usersgroups: %w", err))
 		}
 	}()
+	if !a.exports(&tables.UserGroupsRow{}) {
+		return nil
+	}
 	a.Logger.Info("exporting usersgroups")
-	return a.SlackClient.ListUserGroups(ctx, a.BigQueryJobClient.PutUserGroups)
+	return a.SlackClient.ListUserGroups(ctx, a.Sink.PutUserGroups)
 }
 
 func (a *App) exportChannels(ctx context.Context) (err error) {
@@ -64,37 +314,307 @@ func (a *App) exportChannels(ctx context.Context) (err error) {
 			err = fmt.Errorf("export channels: %w", err)
 		}
 	}()
-	a.Logger.Info("exporting channels")
-	return a.SlackClient.ListChannels(ctx, func(ctx context.Context, channels []slack.Channel) error {
-		if err := a.BigQueryJobClient.PutChannels(ctx, channels); err != nil {
-			return err
+	// Channels are listed also when only their members, messages, reactions, pins or bookmarks are exported.
+	if !a.exports(&tables.ChannelsRow{}) &&
+		!a.exports(&tables.ChannelMembersRow{}) &&
+		!a.exports(&tables.MessagesRow{}) &&
+		!a.exports(&tables.ReactionsRow{}) &&
+		!a.exports(&tables.PinsRow{}) &&
+		!a.exports(&tables.BookmarksRow{}) {
+		return nil
+	}
+	a.Logger.Info("exporting channels", zap.Int("concurrency", a.Config.Concurrency))
+	pool, ctx := workerpool.New(ctx, a.Config.Concurrency)
+	includeArchived := a.Config.Job.IncludeArchivedChannels
+	errList := a.SlackClient.ListChannels(ctx, includeArchived, func(ctx context.Context, channels []slack.Channel) error {
+		if a.exports(&tables.ChannelsRow{}) {
+			if err := a.putChannels(ctx, channels); err != nil {
+				return err
+			}
 		}
 		for _, channel := range channels {
 			channel := channel
-			if err := a.exportChannelMembers(ctx, &channel); err != nil {
-				return err
+			if a.Checkpoints.Done(checkpointKeyChannel + channel.ID) {
+				continue
 			}
+			pool.Go(func(ctx context.Context) error {
+				if err := a.exportChannel(ctx, &channel); err != nil {
+					return a.nonFatal(err)
+				}
+				a.Checkpoints.MarkDone(checkpointKeyChannel + channel.ID)
+				return nil
+			})
 		}
 		return nil
 	})
+	if errList != nil {
+		pool.Cancel()
+	}
+	if err := pool.Wait(); err != nil {
+		return err
+	}
+	return withTable(&tables.ChannelsRow{}, errList)
+}
+
+// putChannels writes the rows of the channels that were not written by a previous run of the job, since
+// conversations.list is listed again from the start when resuming and the rows would otherwise be written twice.
+func (a *App) putChannels(ctx context.Context, channels []slack.Channel) error {
+	put := make([]slack.Channel, 0, len(channels))
+	for _, channel := range channels {
+		if !a.Checkpoints.Done(checkpointKeyChannelRow + channel.ID) {
+			put = append(put, channel)
+		}
+	}
+	if len(put) == 0 {
+		return nil
+	}
+	if err := a.Sink.PutChannels(ctx, put); err != nil {
+		return err
+	}
+	for _, channel := range put {
+		a.Checkpoints.MarkDone(checkpointKeyChannelRow + channel.ID)
+	}
+	return nil
+}
+
+// exportChannel exports the data of a single channel.
+func (a *App) exportChannel(ctx context.Context, channel *slack.Channel) error {
+	if err := a.exportChannelMembers(ctx, channel); err != nil {
+		return err
+	}
+	if err := a.exportPins(ctx, channel); err != nil {
+		return err
+	}
+	if err := a.exportBookmarks(ctx, channel); err != nil {
+		return err
+	}
+	return a.exportMessages(ctx, channel)
 }
 
 func (a *App) exportChannelMembers(ctx context.Context, channel *slack.Channel) (err error) {
 	defer func() {
 		if err != nil {
-			err = fmt.Errorf("export channelmembers: %w", err)
+			err = withTable(&tables.ChannelMembersRow{}, fmt.Errorf("export channelmembers: %w", err))
+		}
+	}()
+	if !a.exports(&tables.ChannelMembersRow{}) {
+		return nil
+	}
+	a.Logger.Info("exporting channelmembers", zap.String("channel", channel.ID))
+	return a.SlackClient.ListChannelMembers(ctx, channel, a.Sink.PutChannelMembers)
+}
+
+func (a *App) exportPins(ctx context.Context, channel *slack.Channel) (err error) {
+	defer func() {
+		if err != nil {
+			err = withTable(&tables.PinsRow{}, fmt.Errorf("export pins: %w", err))
+		}
+	}()
+	if !a.exports(&tables.PinsRow{}) {
+		return nil
+	}
+	if !channel.IsMember {
+		a.Logger.Debug("skipping pins of channel without membership", zap.String("channel", channel.ID))
+		return nil
+	}
+	a.Logger.Info("exporting pins", zap.String("channel", channel.ID))
+	return a.SlackClient.ListPins(ctx, channel, a.Sink.PutPins)
+}
+
+func (a *App) exportBookmarks(ctx context.Context, channel *slack.Channel) (err error) {
+	defer func() {
+		if err != nil {
+			err = withTable(&tables.BookmarksRow{}, fmt.Errorf("export bookmarks: %w", err))
 		}
 	}()
-	a.Logger.Info("exporting channelmembers")
-	return a.SlackClient.ListChannelMembers(ctx, channel, a.BigQueryJobClient.PutChannelMembers)
+	if !a.exports(&tables.BookmarksRow{}) {
+		return nil
+	}
+	if !channel.IsMember {
+		a.Logger.Debug("skipping bookmarks of channel without membership", zap.String("channel", channel.ID))
+		return nil
+	}
+	a.Logger.Info("exporting bookmarks", zap.String("channel", channel.ID))
+	return a.SlackClient.ListBookmarks(ctx, channel, a.Sink.PutBookmarks)
 }
 
-func (a *App) exportFiles(ctx context.Context) (err error) {
+func (a *App) exportMessages(ctx context.Context, channel *slack.Channel) (err error) {
+	// Messages are listed also when only their reactions are exported.
+	var row tables.Row = &tables.MessagesRow{}
+	if !a.exports(row) {
+		row = &tables.ReactionsRow{}
+	}
 	defer func() {
 		if err != nil {
-			err = fmt.Errorf("exporting files: %w", err)
+			err = fmt.Errorf("export messages: %w", err)
+			var errTable *tableError
+			if !errors.As(err, &errTable) {
+				err = withTable(row, err)
+			}
 		}
 	}()
+	if !a.exports(row) {
+		return nil
+	}
+	if !channel.IsMember {
+		a.Logger.Debug("skipping messages of channel without membership", zap.String("channel", channel.ID))
+		return nil
+	}
+	a.Logger.Info("exporting messages", zap.String("channel", channel.ID))
+	oldest, latest := a.Config.Job.MessagesWindow()
+	// Messages are listed from the start of the threads window, so that new replies to older threads are exported.
+	// Messages posted before the messages window are only used to find their replies.
+	threadsOldest, _ := a.Config.Job.ThreadsWindow()
+	return a.SlackClient.ListMessages(
+		ctx,
+		channel,
+		threadsOldest,
+		latest,
+		func(ctx context.Context, channel *slack.Channel, messages []slack.Message) error {
+			inWindow := make([]slack.Message, 0, len(messages))
+			for _, message := range messages {
+				if !tables.ParseTimestamp(message.Timestamp).Before(oldest) {
+					inWindow = append(inWindow, message)
+				}
+			}
+			if err := a.putMessages(ctx, channel, inWindow); err != nil {
+				return err
+			}
+			for _, message := range messages {
+				message := message
+				if message.LatestReply != "" && tables.ParseTimestamp(message.LatestReply).Before(oldest) {
+					continue
+				}
+				if err := a.exportThreadReplies(ctx, channel, &message, oldest, latest); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// putMessages writes messages posted in a channel and their reactions to the tables exported by the job.
+func (a *App) putMessages(ctx context.Context, channel *slack.Channel, messages []slack.Message) error {
+	if a.exports(&tables.MessagesRow{}) {
+		if err := a.Sink.PutMessages(ctx, channel, messages); err != nil {
+			return withTable(&tables.MessagesRow{}, err)
+		}
+	}
+	if a.exports(&tables.ReactionsRow{}) {
+		if err := a.Sink.PutReactions(ctx, channel, messages); err != nil {
+			return withTable(&tables.ReactionsRow{}, err)
+		}
+	}
+	return nil
+}
+
+func (a *App) exportThreadReplies(
+	ctx context.Context,
+	channel *slack.Channel,
+	parent *slack.Message,
+	oldest time.Time,
+	latest time.Time,
+) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("export thread replies: %w", err)
+		}
+	}()
+	if parent.ReplyCount == 0 {
+		return nil
+	}
+	a.Logger.Debug("exporting thread replies", zap.String("channel", channel.ID), zap.String("ts", parent.Timestamp))
+	return a.SlackClient.ListThreadReplies(ctx, channel, parent, oldest, latest, a.putMessages)
+}
+
+// exportDirectConversations exports the metadata and members of direct conversations, when enabled.
+// The messages of direct conversations are not exported.
+func (a *App) exportDirectConversations(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			err = withTable(&tables.DirectConversationsRow{}, fmt.Errorf("export direct conversations: %w", err))
+		}
+	}()
+	if !a.exports(&tables.DirectConversationsRow{}) {
+		return nil
+	}
+	a.Logger.Info("exporting direct conversations")
+	pool, ctx := workerpool.New(ctx, a.Config.Concurrency)
+	errList := a.SlackClient.ListDirectConversations(ctx, func(ctx context.Context, conversations []slack.Channel) error {
+		for _, conversation := range conversations {
+			conversation := conversation
+			if a.Checkpoints.Done(checkpointKeyDirectConversation + conversation.ID) {
+				continue
+			}
+			pool.Go(func(ctx context.Context) error {
+				if err := a.exportDirectConversation(ctx, &conversation); err != nil {
+					return a.nonFatal(err)
+				}
+				a.Checkpoints.MarkDone(checkpointKeyDirectConversation + conversation.ID)
+				return nil
+			})
+		}
+		return nil
+	})
+	if errList != nil {
+		pool.Cancel()
+	}
+	if err := pool.Wait(); err != nil {
+		return err
+	}
+	return errList
+}
+
+func (a *App) exportDirectConversation(ctx context.Context, conversation *slack.Channel) error {
+	var members []string
+	if err := a.SlackClient.ListChannelMembers(
+		ctx,
+		conversation,
+		func(_ context.Context, _ *slack.Channel, page []string) error {
+			members = append(members, page...)
+			return nil
+		},
+	); err != nil {
+		return err
+	}
+	return a.Sink.PutDirectConversation(ctx, conversation, members)
+}
+
+func (a *App) exportFiles(ctx context.Context, window tables.FilesWindow) (err error) {
+	defer func() {
+		if err != nil {
+			err = withTable(&tables.FilesRow{}, fmt.Errorf("exporting files: %w", err))
+		}
+	}()
+	if !a.exports(&tables.FilesRow{}) {
+		return nil
+	}
 	a.Logger.Info("exporting files")
-	return a.SlackClient.ListFiles(ctx, a.BigQueryJobClient.PutFiles)
+	return a.SlackClient.ListFiles(
+		ctx,
+		window.FilesFrom.Timestamp,
+		window.FilesTo.Timestamp,
+		a.Checkpoints.Cursor(filesCursorKey(window)),
+		func(ctx context.Context, files []slack.File, nextCursor string) error {
+			if err := a.Sink.PutFiles(ctx, files); err != nil {
+				return err
+			}
+			a.Checkpoints.SetCursor(filesCursorKey(window), nextCursor)
+			return nil
+		},
+	)
+}
+
+func (a *App) exportEmoji(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			err = withTable(&tables.EmojiRow{}, fmt.Errorf("export emoji: %w", err))
+		}
+	}()
+	if !a.exports(&tables.EmojiRow{}) {
+		return nil
+	}
+	a.Logger.Info("exporting emoji")
+	return a.SlackClient.ListEmoji(ctx, a.Sink.PutEmoji)
 }
@@ -0,0 +1,276 @@
+package app_test
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/einride/bigquery-importer-slack/internal/app"
+	"github.com/einride/bigquery-importer-slack/internal/checkpoint"
+	"github.com/einride/bigquery-importer-slack/internal/memsink"
+	"github.com/einride/bigquery-importer-slack/internal/slackfake"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+// jobDate is the date of the jobs run by the tests. Messages are exported from the day before.
+var jobDate = civil.Date{Year: 2022, Month: 10, Day: 17}
+
+// timestamp returns the Slack timestamp of the message posted at an offset from the start of the job date.
+func timestamp(offset time.Duration) string {
+	t := jobDate.In(time.UTC).Add(offset)
+	return strconv.FormatInt(t.Unix(), 10) + ".000000"
+}
+
+func channel(id, name string, isMember bool) slack.Channel {
+	var c slack.Channel
+	c.ID, c.Name, c.IsChannel, c.IsMember = id, name, true, isMember
+	return c
+}
+
+func message(ts, threadTS string, replyCount int) slack.Message {
+	var m slack.Message
+	m.Type, m.User, m.Text = "message", "U1", "posted at "+ts
+	m.Timestamp, m.ThreadTimestamp, m.ReplyCount = ts, threadTS, replyCount
+	return m
+}
+
+func testData() slackfake.Data {
+	parent := timestamp(-2 * time.Hour)
+	return slackfake.Data{
+		Team:  slack.TeamInfo{ID: "T1", Name: "team"},
+		Users: []slack.User{{ID: "U1", Name: "alice"}, {ID: "U2", Name: "bob"}, {ID: "U3", Name: "carol"}},
+		Channels: []slack.Channel{
+			channel("C1", "general", true),
+			channel("C2", "random", true),
+			channel("C3", "private", false),
+		},
+		ChannelMembers: map[string][]string{
+			"C1": {"U1", "U2", "U3"},
+			"C2": {"U1"},
+			"C3": {"U2", "U3"},
+		},
+		Messages: map[string][]slack.Message{
+			"C1": {
+				message(timestamp(-48*time.Hour), "", 0),
+				message(timestamp(-3*time.Hour), "", 0),
+				message(parent, parent, 2),
+				message(timestamp(-90*time.Minute), parent, 0),
+				message(timestamp(-time.Hour), parent, 0),
+				message(timestamp(-30*time.Minute), "", 0),
+			},
+			"C2": {
+				message(timestamp(-time.Minute), "", 0),
+			},
+		},
+		Files: []slack.File{
+			{ID: "F1", Name: "a.txt", Created: slack.JSONTime(jobDate.In(time.UTC).Add(-time.Hour).Unix())},
+			{ID: "F2", Name: "b.txt", Created: slack.JSONTime(jobDate.In(time.UTC).Add(-time.Hour).Unix())},
+			{ID: "F3", Name: "c.txt", Created: slack.JSONTime(jobDate.In(time.UTC).Add(-time.Hour).Unix())},
+		},
+	}
+}
+
+// newApp returns an App exporting from the fake server into an in-memory sink.
+func newApp(t *testing.T, server *slackfake.Server, configure func(*app.Config)) (*app.App, *memsink.Sink) {
+	t.Helper()
+	ctx := context.Background()
+	var config app.Config
+	config.Concurrency = 2
+	config.SlackClient.APIKey = "xoxb-test"
+	config.SlackClient.APIURL = server.URL()
+	config.SlackClient.MaxRetries = 2
+	config.SlackClient.RequestTimeout = 10 * time.Second
+	config.SlackClient.RateLimitWindow = time.Millisecond
+	config.SlackClient.MinBackoff = time.Millisecond
+	config.SlackClient.MaxBackoff = time.Millisecond
+	config.Job.Org = "einride"
+	config.Job.ID = uuid.New()
+	config.Job.Date = jobDate
+	config.Job.MessagesLookback = 24 * time.Hour
+	config.Job.ConflictPolicy = "fail"
+	config.Job.WriteMode = "stream"
+	if configure != nil {
+		configure(&config)
+	}
+	logger := zap.NewNop()
+	client, err := app.InitSlackClient(ctx, &config, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &memsink.Sink{JobConfig: config.Job, Logger: logger}
+	return &app.App{
+		Config:      &config,
+		Sink:        sink,
+		SlackClient: client,
+		Logger:      logger,
+	}, sink
+}
+
+// column returns the sorted values of a column of a committed table.
+func column(t *testing.T, sink *memsink.Sink, tableName, name string) []string {
+	t.Helper()
+	table, ok := sink.Table(tableName + "_20221017")
+	if !ok {
+		t.Fatalf("table %s was not committed", tableName)
+	}
+	values := make([]string, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		values = append(values, row.Values[name].(string))
+	}
+	sort.Strings(values)
+	return values
+}
+
+func assertColumn(t *testing.T, sink *memsink.Sink, tableName, name string, want ...string) {
+	t.Helper()
+	got := column(t, sink, tableName, name)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("%s.%s: got %q, want %q", tableName, name, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s.%s: got %q, want %q", tableName, name, got, want)
+		}
+	}
+}
+
+func TestApp_Run(t *testing.T) {
+	t.Parallel()
+	server := slackfake.NewServer(testData())
+	defer server.Close()
+	// Small pages make every paginated method follow its cursors.
+	server.PageSize = 2
+	a, sink := newApp(t, server, func(config *app.Config) {
+		config.Job.Tables = []string{"users", "channels", "channel_members", "messages", "files"}
+	})
+	if err := a.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertColumn(t, sink, "users", "id", "U1", "U2", "U3")
+	assertColumn(t, sink, "channels", "id", "C1", "C2", "C3")
+	assertColumn(t, sink, "channel_members", "channel_id", "C1", "C1", "C1", "C2", "C3", "C3")
+	// Messages posted before the messages window are not exported, while thread replies are.
+	assertColumn(
+		t, sink, "messages", "ts",
+		timestamp(-3*time.Hour),
+		timestamp(-2*time.Hour),
+		timestamp(-90*time.Minute),
+		timestamp(-time.Hour),
+		timestamp(-30*time.Minute),
+		timestamp(-time.Minute),
+	)
+	assertColumn(t, sink, "files", "id", "F1", "F2", "F3")
+	for method, want := range map[string]int{"users.list": 2, "conversations.list": 2, "files.list": 2} {
+		if got := server.Calls(method); got != want {
+			t.Errorf("%s: got %d calls, want %d", method, got, want)
+		}
+	}
+}
+
+func TestApp_Run_resume(t *testing.T) {
+	t.Parallel()
+	server := slackfake.NewServer(testData())
+	defer server.Close()
+	a, sink := newApp(t, server, func(config *app.Config) {
+		config.Job.Tables = []string{"channels"}
+	})
+	// A previous run of the job wrote the row of C1 before it was interrupted.
+	dir := t.TempDir()
+	previous, err := checkpoint.Open(dir, a.Config.Job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.MarkDone("channel_row:C1")
+	if err := previous.Save(context.Background(), func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if a.Checkpoints, err = checkpoint.Open(dir, a.Config.Job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertColumn(t, sink, "channels", "id", "C2", "C3")
+}
+
+func TestApp_Run_archivedChannels(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		name            string
+		includeArchived bool
+		want            []string
+	}{
+		{name: "excluded", want: []string{"C1", "C3"}},
+		{name: "included", includeArchived: true, want: []string{"C1", "C2", "C3"}},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := slackfake.NewServer(testData())
+			defer server.Close()
+			server.Data.Channels[1].IsArchived = true
+			a, sink := newApp(t, server, func(config *app.Config) {
+				config.Job.Tables = []string{"channels", "channel_members"}
+				config.Job.IncludeArchivedChannels = tt.includeArchived
+			})
+			if err := a.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			assertColumn(t, sink, "channels", "id", tt.want...)
+		})
+	}
+}
+
+func TestApp_Run_retry(t *testing.T) {
+	t.Parallel()
+	server := slackfake.NewServer(testData())
+	defer server.Close()
+	// The first request to each method is rate limited or fails, and must be retried rather than skipped.
+	server.Inject("users.list", slackfake.RateLimited(0))
+	server.Inject("conversations.history", slackfake.StatusError(http.StatusServiceUnavailable), slackfake.RateLimited(0))
+	server.Inject("files.list", slackfake.RateLimited(0))
+	server.Inject("pins.list", slackfake.RateLimited(0))
+	server.Data.Pins = map[string][]tables.PinnedItem{"C1": {{Item: slack.NewFileItem(&server.Data.Files[0])}}}
+	a, sink := newApp(t, server, func(config *app.Config) {
+		config.Job.Tables = []string{"users", "channels", "messages", "files", "pins"}
+	})
+	if err := a.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertColumn(t, sink, "users", "id", "U1", "U2", "U3")
+	assertColumn(t, sink, "channels", "id", "C1", "C2", "C3")
+	if got, want := len(column(t, sink, "messages", "ts")), 6; got != want {
+		t.Errorf("got %d messages, want %d", got, want)
+	}
+	assertColumn(t, sink, "files", "id", "F1", "F2", "F3")
+	assertColumn(t, sink, "pins", "file_id", "F1")
+	for method, want := range map[string]int{"users.list": 2, "conversations.list": 1, "files.list": 2, "pins.list": 3} {
+		if got := server.Calls(method); got != want {
+			t.Errorf("%s: got %d calls, want %d", method, got, want)
+		}
+	}
+}
+
+func TestApp_Run_retriesExhausted(t *testing.T) {
+	t.Parallel()
+	server := slackfake.NewServer(testData())
+	defer server.Close()
+	server.Inject("files.list", slackfake.RateLimited(0), slackfake.RateLimited(0), slackfake.RateLimited(0))
+	a, sink := newApp(t, server, func(config *app.Config) {
+		config.Job.Tables = []string{"files"}
+	})
+	if err := a.Run(context.Background()); err == nil {
+		t.Fatal("expected an error when retries are exhausted")
+	}
+	if _, ok := sink.Table("files_20221017"); ok {
+		t.Error("files table was committed by a failed job")
+	}
+}
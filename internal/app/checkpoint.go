@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"go.uber.org/zap"
+)
+
+// checkpointTimeout is the timeout for saving the checkpoint of an interrupted run.
+const checkpointTimeout = 30 * time.Second
+
+// Checkpoint keys of the units of work of a run.
+const (
+	checkpointKeyExport             = "export:"
+	checkpointKeyChannel            = "channel:"
+	checkpointKeyChannelRow         = "channel_row:"
+	checkpointKeyDirectConversation = "direct_conversation:"
+	checkpointKeyUserProfile        = "user_profile:"
+	checkpointKeyFilesCursor        = "files.list:"
+)
+
+// filesCursorKey returns the checkpoint key of the files.list cursor when exporting the files of a window.
+// The cursor of a files window is a page number while the cursor of all files is opaque, so the key depends on whether
+// the files are exported incrementally, and a resumed run that changed JOB_INCREMENTALFILES starts over.
+func filesCursorKey(window tables.FilesWindow) string {
+	if window.FilesTo.Valid {
+		return checkpointKeyFilesCursor + "page"
+	}
+	return checkpointKeyFilesCursor + "cursor"
+}
+
+// startCheckpoints periodically saves the progress of the run, until the returned function is called.
+func (a *App) startCheckpoints(ctx context.Context) (stop func()) {
+	if a.Checkpoints == nil || a.Config.Checkpoint.Interval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(a.Config.Checkpoint.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := a.Checkpoints.Save(ctx, a.Sink.Flush); err != nil {
+					a.Logger.Warn("save checkpoint", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// finishCheckpoints removes the checkpoint of a committed run, and saves the progress of an interrupted run so that
+// it can be resumed. The checkpoint is saved with a fresh context, so that it is saved also when the run was
+// cancelled.
+func (a *App) finishCheckpoints(committed bool) error {
+	if committed {
+		return a.Checkpoints.Remove()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+	defer cancel()
+	return a.Checkpoints.Save(ctx, a.Sink.Flush)
+}
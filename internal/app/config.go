@@ -1,6 +1,11 @@
 package app
 
-import "github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+import (
+	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+	"github.com/einride/bigquery-importer-slack/internal/api/slackapi"
+	"github.com/einride/bigquery-importer-slack/internal/checkpoint"
+	"github.com/einride/bigquery-importer-slack/internal/filesink"
+)
 
 type Config struct {
 	Logger struct {
@@ -9,13 +14,19 @@ type Config struct {
 		Development bool   `required:"true"`
 	}
 
+	Sink SinkType `default:"bigquery"`
+
+	Concurrency int `default:"4"`
+
 	BigQueryClient struct {
-		ProjectID string `required:"true"`
+		ProjectID string
 	}
 
-	SlackClient struct {
-		APIKeySecret string `required:"true"`
-	}
+	FileSink filesink.Config
+
+	SlackClient slackapi.Config
 
 	Job bigqueryapi.JobConfig
+
+	Checkpoint checkpoint.Config
 }
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+)
+
+// tableError is an error that occurred while exporting a table.
+type tableError struct {
+	table string
+	err   error
+}
+
+// withTable attributes an error to the table of the row type.
+func withTable(row tables.Row, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &tableError{table: row.TableName(), err: err}
+}
+
+func (e *tableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *tableError) Unwrap() error {
+	return e.err
+}
+
+// tableErrors returns the errors attributed to each table within an error, which may aggregate multiple errors.
+func tableErrors(err error) []tables.JobRunTableError {
+	byTable := make(map[string]*tables.JobRunTableError)
+	var walk func(error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+		if group, ok := err.(interface{ Errors() []error }); ok {
+			for _, err := range group.Errors() {
+				walk(err)
+			}
+			return
+		}
+		if errTable, ok := err.(*tableError); ok {
+			tableErr, ok := byTable[errTable.table]
+			if !ok {
+				tableErr = &tables.JobRunTableError{Name: errTable.table, Error: errTable.Error()}
+				byTable[errTable.table] = tableErr
+			}
+			tableErr.Count++
+			return
+		}
+		walk(errors.Unwrap(err))
+	}
+	walk(err)
+	result := make([]tables.JobRunTableError, 0, len(byTable))
+	for _, tableErr := range byTable {
+		result = append(result, *tableErr)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
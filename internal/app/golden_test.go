@@ -0,0 +1,148 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/einride/bigquery-importer-slack/internal/app"
+	"github.com/einride/bigquery-importer-slack/internal/memsink"
+	"github.com/einride/bigquery-importer-slack/internal/slackfake"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// goldenData returns a workspace with content for every table, for comparing the exported rows with golden files.
+func goldenData() slackfake.Data {
+	data := testData()
+	created := slack.JSONTime(jobDate.In(time.UTC).Add(-time.Hour).Unix())
+	data.Team.Domain = "einride"
+	data.EnterpriseID = "E1"
+	data.TeamProfile.Fields = []slack.TeamProfileField{
+		{ID: "Xf1", Ordering: 0, Label: "Title", Type: "text"},
+		{ID: "Xf2", Ordering: 1, Label: "Office", Type: "options_list", PossibleValues: []string{"Stockholm", "Gothenburg"}},
+	}
+	data.Users[0].RealName, data.Users[0].IsAdmin = "Alice", true
+	data.Users[0].Profile.Email = "alice@example.com"
+	data.Users[0].Profile.Fields.SetMap(map[string]slack.UserProfileCustomField{
+		"Xf1": {Value: "Engineer"},
+		"Xf2": {Value: "Stockholm"},
+	})
+	data.Users[1].Deleted = true
+	data.Users[2].IsBot = true
+	data.UserGroups = []slack.UserGroup{
+		{ID: "S1", Name: "engineers", Handle: "eng", DateCreate: created, Users: []string{"U1", "U2"}, UserCount: 2},
+	}
+	var im, mpim slack.Channel
+	im.ID, im.IsIM, im.User = "D1", true, "U2"
+	mpim.ID, mpim.Name, mpim.IsMpIM = "G1", "mpdm-alice--bob--carol-1", true
+	data.Channels = append(data.Channels, im, mpim)
+	data.ChannelMembers["D1"] = []string{"U1", "U2"}
+	data.ChannelMembers["G1"] = []string{"U1", "U2", "U3"}
+	reacted := data.Messages["C1"][1]
+	reacted.Reactions = []slack.ItemReaction{
+		{Name: "thumbsup", Count: 5, Users: []string{"U1", "U2"}},
+		{Name: "tada", Count: 1, Users: []string{"U3"}},
+	}
+	// The users of a reaction are truncated in message payloads, unlike its count.
+	data.Messages["C1"][1] = reacted
+	broadcast := message(timestamp(-45*time.Minute), data.Messages["C1"][2].Timestamp, 0)
+	broadcast.SubType = slack.MsgSubTypeThreadBroadcast
+	data.Messages["C1"] = append(data.Messages["C1"], broadcast)
+	data.Files[0].Title, data.Files[0].Filetype, data.Files[0].Size = "A", "text", 42
+	data.Files[0].Channels = []string{"C1"}
+	data.Emoji = map[string]string{"party": "https://emoji.example.com/party.png", "tada2": "alias:tada"}
+	pinned := data.Messages["C1"][1]
+	data.Pins = map[string][]tables.PinnedItem{
+		"C1": {{Item: slack.NewMessageItem("C1", &pinned), Created: created, CreatedBy: "U2"}},
+	}
+	data.Bookmarks = map[string][]slack.Bookmark{
+		"C1": {{ID: "Bk1", ChannelID: "C1", Title: "Docs", Link: "https://example.com", Type: "link", Created: created}},
+	}
+	return data
+}
+
+// goldenRows returns the rows of a committed table as indented JSON, sorted and without the export time.
+func goldenRows(t *testing.T, table *memsink.Table) []byte {
+	t.Helper()
+	rows := make([]string, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		values := make(map[string]interface{}, len(row.Values))
+		for name, value := range row.Values {
+			if name != "exported_at" {
+				values[name] = value
+			}
+		}
+		data, err := json.Marshal(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, string(data))
+	}
+	sort.Strings(rows)
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte("["+strings.Join(rows, ",")+"]"), "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+func TestApp_Run_golden(t *testing.T) {
+	t.Parallel()
+	server := slackfake.NewServer(goldenData())
+	defer server.Close()
+	a, sink := newApp(t, server, func(config *app.Config) {
+		config.Job.ID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+		config.Job.Messages = true
+		config.Job.DirectConversations = true
+		config.Job.UserProfileFields = true
+		config.Job.Emoji = true
+		config.Job.Pins = true
+		config.Job.Bookmarks = true
+		config.Job.Team = true
+	})
+	if err := a.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The team profile is shared by the team and user profile fields tables.
+	if got := server.Calls("team.profile.get"); got != 1 {
+		t.Errorf("team.profile.get: got %d calls, want 1", got)
+	}
+	for _, row := range a.Config.Job.ExportedTables() {
+		row := row
+		t.Run(row.TableName(), func(t *testing.T) {
+			table, ok := sink.Table(row.TableID(jobDate))
+			if !ok {
+				t.Fatalf("table %s was not committed", row.TableName())
+			}
+			got := goldenRows(t, table)
+			path := filepath.Join("testdata", "golden", row.TableName()+".json")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("rows of %s do not match %s, update it with -update if intended:\n%s", row.TableName(), path, got)
+			}
+		})
+	}
+}
@@ -3,10 +3,17 @@ package app
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"cloud.google.com/go/bigquery"
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"github.com/blendle/zapdriver"
+	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+	"github.com/einride/bigquery-importer-slack/internal/api/slackapi"
+	"github.com/einride/bigquery-importer-slack/internal/checkpoint"
+	"github.com/einride/bigquery-importer-slack/internal/filesink"
+	"github.com/einride/bigquery-importer-slack/internal/memsink"
 	"github.com/slack-go/slack"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -16,18 +23,75 @@ import (
 func InitSlackClient(
 	ctx context.Context,
 	config *Config,
-	secretmanager *secretmanager.Client,
 	logger *zap.Logger,
-) (*slack.Client, error) {
+) (_ *slackapi.SlackClient, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("init Slack client: %w", err)
+		}
+	}()
 	logger.Info("init Slack client", zap.Any("cfg", config.SlackClient))
-	accessRequest := &secretmanagerpb.AccessSecretVersionRequest{
-		Name: config.SlackClient.APIKeySecret,
+	// The HTTP client is shared with the slack package, for the methods that are called without it.
+	httpClient := &http.Client{}
+	options := []slack.Option{slack.OptionHTTPClient(httpClient)}
+	if config.SlackClient.APIURL != "" {
+		// The API URL is the base of the Web API method URLs, so it must end with a slash.
+		options = append(options, slack.OptionAPIURL(strings.TrimSuffix(config.SlackClient.APIURL, "/")+"/"))
+	}
+	// The API key is kept in the config of the client, for the methods that are called without the slack package.
+	clientConfig := config.SlackClient
+	if clientConfig.APIKey == "" {
+		if clientConfig.APIKeySecret == "" {
+			return nil, fmt.Errorf("one of API key and API key secret is required")
+		}
+		secretmanager, cleanup, err := InitSecretManagerClient(ctx, logger)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		accessRequest := &secretmanagerpb.AccessSecretVersionRequest{
+			Name: clientConfig.APIKeySecret,
+		}
+		APIKey, err := secretmanager.AccessSecretVersion(ctx, accessRequest)
+		if err != nil {
+			return nil, err
+		}
+		clientConfig.APIKey = string(APIKey.Payload.Data)
+	}
+	return &slackapi.SlackClient{
+		Config:     clientConfig,
+		Client:     slack.New(clientConfig.APIKey, options...),
+		Logger:     logger,
+		HTTPClient: httpClient,
+	}, nil
+}
+
+// InitCheckpoints opens the checkpoint store of the job, or returns nil when checkpoints are disabled.
+func InitCheckpoints(
+	config *Config,
+	logger *zap.Logger,
+) (_ *checkpoint.Store, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("init checkpoints: %w", err)
+		}
+	}()
+	if config.Checkpoint.Dir == "" {
+		return nil, nil
 	}
-	APIKey, err := secretmanager.AccessSecretVersion(ctx, accessRequest)
+	logger.Info("init checkpoints", zap.Any("config", config.Checkpoint))
+	// Rows must be written durably before a checkpoint is saved, which is only possible when streaming rows.
+	if config.Sink != SinkTypeBigQuery || config.Job.WriteMode != bigqueryapi.WriteModeStream {
+		return nil, fmt.Errorf("checkpoints require the bigquery sink with the stream write mode")
+	}
+	store, err := checkpoint.Open(config.Checkpoint.Dir, config.Job.ID)
 	if err != nil {
 		return nil, err
 	}
-	return slack.New(string(APIKey.Payload.Data)), nil
+	if store.Resuming() {
+		logger.Info("found checkpoint of interrupted job", zap.Stringer("id", config.Job.ID))
+	}
+	return store, nil
 }
 
 func InitSecretManagerClient(
@@ -48,6 +112,60 @@ func InitSecretManagerClient(
 	return client, cleanup, nil
 }
 
+func InitSink(
+	ctx context.Context,
+	config *Config,
+	logger *zap.Logger,
+) (_ Sink, _ func(), err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("init sink: %w", err)
+		}
+	}()
+	logger.Info("init sink", zap.String("type", string(config.Sink)))
+	if err := config.Job.ValidateTables(); err != nil {
+		return nil, nil, err
+	}
+	switch config.Sink {
+	case SinkTypeBigQuery:
+		if config.Job.Dataset == "" {
+			return nil, nil, fmt.Errorf("job dataset is required")
+		}
+		if config.Job.IncrementalFiles && config.Job.Partitioning == bigqueryapi.PartitioningNone {
+			return nil, nil, fmt.Errorf("incremental files require a partitioned files table")
+		}
+		client, cleanup, err := InitBigQueryClient(ctx, config, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &bigqueryapi.JobClient{
+			Config:         config.Job,
+			BigQueryClient: client,
+			Logger:         logger,
+		}, cleanup, nil
+	case SinkTypeFile:
+		logger.Info("init file sink", zap.Any("config", config.FileSink))
+		if err := filesink.ValidateConflictPolicy(config.Job.ConflictPolicy); err != nil {
+			return nil, nil, err
+		}
+		return &filesink.Sink{
+			Config:    config.FileSink,
+			JobConfig: config.Job,
+			Logger:    logger,
+		}, func() {}, nil
+	case SinkTypeMemory:
+		// The memory sink validates the exported rows against the golden schemas of their tables and counts them, but
+		// discards them, e.g. for a dry run of the job.
+		return &memsink.Sink{
+			JobConfig: config.Job,
+			Logger:    logger,
+			Discard:   true,
+		}, func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown sink type: %s", config.Sink)
+	}
+}
+
 func InitBigQueryClient(
 	ctx context.Context,
 	config *Config,
@@ -59,6 +177,9 @@ func InitBigQueryClient(
 		}
 	}()
 	logger.Info("init BigQuery client", zap.Any("config", config.BigQueryClient))
+	if config.BigQueryClient.ProjectID == "" {
+		return nil, nil, fmt.Errorf("project ID is required")
+	}
 	client, err := bigquery.NewClient(ctx, config.BigQueryClient.ProjectID)
 	if err != nil {
 		return nil, nil, err
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+
+	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+	"github.com/einride/bigquery-importer-slack/internal/filesink"
+	"github.com/einride/bigquery-importer-slack/internal/memsink"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"github.com/slack-go/slack"
+)
+
+// Sink is a destination that the exported Slack data is written to.
+type Sink interface {
+	// EnsureTables prepares the tables of the job for writing.
+	EnsureTables(context.Context) error
+	// ResumeTables prepares the tables of an interrupted run of the job for writing more rows.
+	ResumeTables(context.Context) error
+	PutUsers(context.Context, []slack.User) error
+	PutUserGroups(context.Context, []slack.UserGroup) error
+	PutChannels(context.Context, []slack.Channel) error
+	PutChannelMembers(context.Context, *slack.Channel, []string) error
+	PutFiles(context.Context, []slack.File) error
+	PutMessages(context.Context, *slack.Channel, []slack.Message) error
+	PutReactions(context.Context, *slack.Channel, []slack.Message) error
+	PutPins(context.Context, *slack.Channel, []tables.PinnedItem) error
+	PutBookmarks(context.Context, *slack.Channel, []slack.Bookmark) error
+	PutDirectConversation(context.Context, *slack.Channel, []string) error
+	PutEmoji(context.Context, map[string]string) error
+	PutTeam(context.Context, *slack.TeamInfo, *slack.TeamProfile, string) error
+	PutUserProfileFields(context.Context, string, *slack.UserProfile, *slack.TeamProfile) error
+	// PutJobRun records the status of the job run. Unlike other rows it is written immediately.
+	PutJobRun(context.Context, *tables.JobRunsRow) error
+	// LastFilesWindow returns the files window of the latest succeeded run of the org before the job date, if any.
+	LastFilesWindow(context.Context) (*tables.FilesWindow, error)
+	// RowCounts returns the number of rows written to each table by the job.
+	RowCounts() map[string]int
+	// Flush writes the rows passed to the sink durably, so that the progress of the job can be checkpointed.
+	Flush(context.Context) error
+	// Commit publishes the tables written by the job.
+	Commit(context.Context) error
+	// Close releases the resources held by the sink. Tables that have not been committed may be discarded.
+	Close() error
+}
+
+var (
+	_ Sink = &bigqueryapi.JobClient{}
+	_ Sink = &filesink.Sink{}
+	_ Sink = &memsink.Sink{}
+)
+
+// SinkType is the type of Sink that the exported Slack data is written to.
+type SinkType string
+
+const (
+	SinkTypeBigQuery SinkType = "bigquery"
+	SinkTypeFile     SinkType = "file"
+	SinkTypeMemory   SinkType = "memory"
+)
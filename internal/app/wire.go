@@ -5,8 +5,6 @@ package app
 import (
 	"context"
 
-	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
-	"github.com/einride/bigquery-importer-slack/internal/api/slackapi"
 	"github.com/google/wire"
 	"go.uber.org/zap"
 )
@@ -15,11 +13,9 @@ func InitApp(ctx context.Context, logger *zap.Logger, config *Config) (*App, fun
 	panic(
 		wire.Build(
 			wire.Struct(new(App), "*"),
-			InitBigQueryClient,
+			InitSink,
 			InitSlackClient,
-			InitSecretManagerClient,
-			wire.Struct(new(slackapi.SlackClient), "*"),
-			wire.Struct(new(bigqueryapi.JobClient), "*"), wire.FieldsOf(&config, "Job"),
+			InitCheckpoints,
 		),
 	)
 }
@@ -8,46 +8,34 @@ package app
 
 import (
 	"context"
-	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
-	"github.com/einride/bigquery-importer-slack/internal/api/slackapi"
 	"go.uber.org/zap"
 )
 
 // Injectors from wire.go:
 
 func InitApp(ctx context.Context, logger *zap.Logger, config *Config) (*App, func(), error) {
-	jobConfig := config.Job
-	client, cleanup, err := InitBigQueryClient(ctx, config, logger)
+	sink, cleanup, err := InitSink(ctx, config, logger)
 	if err != nil {
 		return nil, nil, err
 	}
-	jobClient := &bigqueryapi.JobClient{
-		Config:         jobConfig,
-		BigQueryClient: client,
-		Logger:         logger,
-	}
-	secretmanagerClient, cleanup2, err := InitSecretManagerClient(ctx, logger)
+	slackClient, err := InitSlackClient(ctx, config, logger)
 	if err != nil {
 		cleanup()
 		return nil, nil, err
 	}
-	slackClient, err := InitSlackClient(ctx, config, secretmanagerClient, logger)
+	store, err := InitCheckpoints(config, logger)
 	if err != nil {
-		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
-	slackapiSlackClient := &slackapi.SlackClient{
-		Client: slackClient,
-		Logger: logger,
-	}
 	app := &App{
-		BigQueryJobClient: jobClient,
-		SlackClient:       slackapiSlackClient,
-		Logger:            logger,
+		Config:      config,
+		Sink:        sink,
+		SlackClient: slackClient,
+		Checkpoints: store,
+		Logger:      logger,
 	}
 	return app, func() {
-		cleanup2()
 		cleanup()
 	}, nil
 }
@@ -0,0 +1,8 @@
+package checkpoint
+
+import "time"
+
+type Config struct {
+	Dir      string
+	Interval time.Duration `default:"1m"`
+}
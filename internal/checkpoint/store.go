@@ -0,0 +1,169 @@
+// Package checkpoint persists the progress of a job, so that an interrupted job can be resumed.
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Store keeps track of the progress of a job and persists it to a local file, <dir>/<job ID>.json.
+//
+// Progress is recorded in memory by MarkDone and SetCursor, and persisted by Save. A nil Store is valid and records
+// nothing, for jobs without checkpoints.
+type Store struct {
+	path     string
+	resuming bool
+
+	mu    sync.Mutex
+	state state
+}
+
+// state is the persisted progress of a job.
+type state struct {
+	JobID     string            `json:"jobId"`
+	Completed map[string]bool   `json:"completed"`
+	Cursors   map[string]string `json:"cursors"`
+}
+
+// Open returns the store of a job in dir, loading the progress of a previous run of the job if any.
+func Open(dir string, jobID uuid.UUID) (_ *Store, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("open checkpoint: %w", err)
+		}
+	}()
+	s := &Store{
+		path: filepath.Join(dir, jobID.String()+".json"),
+		state: state{
+			JobID:     jobID.String(),
+			Completed: make(map[string]bool),
+			Cursors:   make(map[string]string),
+		},
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, err
+	}
+	if s.state.JobID != jobID.String() {
+		return nil, fmt.Errorf("checkpoint %s belongs to job %s", s.path, s.state.JobID)
+	}
+	if s.state.Completed == nil {
+		s.state.Completed = make(map[string]bool)
+	}
+	if s.state.Cursors == nil {
+		s.state.Cursors = make(map[string]string)
+	}
+	s.resuming = true
+	return s, nil
+}
+
+// Resuming reports whether the progress of a previous run of the job was loaded.
+func (s *Store) Resuming() bool {
+	return s != nil && s.resuming
+}
+
+// Done reports whether a unit of work, e.g. the export of a channel, has been completed.
+func (s *Store) Done(key string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Completed[key]
+}
+
+// MarkDone records that a unit of work has been completed.
+// Only mark work as done after its rows have been passed to the sink.
+func (s *Store) MarkDone(key string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Completed[key] = true
+}
+
+// Cursor returns the pagination cursor recorded for a paginated listing, or the empty string.
+func (s *Store) Cursor(key string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Cursors[key]
+}
+
+// SetCursor records the pagination cursor of the next page of a paginated listing.
+// Only set the cursor after the rows of the previous pages have been passed to the sink.
+func (s *Store) SetCursor(key string, cursor string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Cursors[key] = cursor
+}
+
+// Save persists the progress recorded so far.
+// The progress is captured before calling flush, which must write the rows passed to the sink durably, so that
+// the saved progress never gets ahead of the written rows.
+func (s *Store) Save(ctx context.Context, flush func(context.Context) error) (err error) {
+	if s == nil {
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("save checkpoint: %w", err)
+		}
+	}()
+	s.mu.Lock()
+	data, err := json.Marshal(&s.state)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := flush(ctx); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), s.path)
+}
+
+// Remove deletes the persisted progress, e.g. when the job has completed.
+func (s *Store) Remove() error {
+	if s == nil {
+		return nil
+	}
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package filesink
+
+import (
+	"fmt"
+
+	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+)
+
+type Config struct {
+	Dir    string `default:"."`
+	Format Format `default:"ndjson"`
+}
+
+// Format is the file format that tables are written in.
+type Format string
+
+const (
+	// FormatNDJSON writes rows as newline-delimited JSON, the format used by BigQuery load jobs.
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV writes rows as CSV with a header. Nested and repeated fields are encoded as JSON.
+	FormatCSV Format = "csv"
+	// FormatParquet writes rows as Parquet. Date and time fields are encoded as strings.
+	FormatParquet Format = "parquet"
+)
+
+// ValidateConflictPolicy returns an error if the file sink does not support the conflict policy of a job.
+// Existing files either fail the job or are replaced, since each file is written whole when the job is committed.
+func ValidateConflictPolicy(policy bigqueryapi.ConflictPolicy) error {
+	switch policy {
+	case bigqueryapi.ConflictPolicyFail, bigqueryapi.ConflictPolicyTruncate, bigqueryapi.ConflictPolicySwap:
+		return nil
+	default:
+		return fmt.Errorf("conflict policy %s: not supported by the file sink", policy)
+	}
+}
@@ -0,0 +1,27 @@
+package filesink
+
+import (
+	"testing"
+
+	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+)
+
+func TestValidateConflictPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy  bigqueryapi.ConflictPolicy
+		wantErr bool
+	}{
+		{policy: bigqueryapi.ConflictPolicyFail},
+		{policy: bigqueryapi.ConflictPolicyTruncate},
+		{policy: bigqueryapi.ConflictPolicySwap},
+		{policy: bigqueryapi.ConflictPolicyAppend, wantErr: true},
+		{policy: "unknown", wantErr: true},
+	} {
+		tt := tt
+		t.Run(string(tt.policy), func(t *testing.T) {
+			if err := ValidateConflictPolicy(tt.policy); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
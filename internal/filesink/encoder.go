@@ -0,0 +1,190 @@
+package filesink
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// encoder encodes the rows of a table into a file format.
+type encoder interface {
+	// Encode encodes a row, as returned by a bigquery.ValueSaver.
+	Encode(map[string]bigquery.Value) error
+	// Close writes any buffered data. It does not close the underlying writer.
+	Close() error
+}
+
+func newEncoder(format Format, w io.Writer, schema bigquery.Schema) (encoder, error) {
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonEncoder{encoder: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return newCSVEncoder(w, schema)
+	case FormatParquet:
+		return newParquetEncoder(w, schema)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+func (f Format) extension() string {
+	return "." + string(f)
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(values map[string]bigquery.Value) error {
+	return e.encoder.Encode(values)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	schema bigquery.Schema
+}
+
+func newCSVEncoder(w io.Writer, schema bigquery.Schema) (*csvEncoder, error) {
+	e := &csvEncoder{writer: csv.NewWriter(w), schema: schema}
+	header := make([]string, 0, len(schema))
+	for _, field := range schema {
+		header = append(header, field.Name)
+	}
+	if err := e.writer.Write(header); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *csvEncoder) Encode(values map[string]bigquery.Value) error {
+	record := make([]string, 0, len(e.schema))
+	for _, field := range e.schema {
+		value, err := formatCSVValue(values[field.Name])
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", field.Name, err)
+		}
+		record = append(record, value)
+	}
+	return e.writer.Write(record)
+}
+
+func (e *csvEncoder) Close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func formatCSVValue(value bigquery.Value) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case time.Time:
+		return value.Format(time.RFC3339Nano), nil
+	case json.Marshaler:
+		// Nullable values, e.g. bigquery.NullString, are encoded as JSON, and as empty strings when null.
+		data, err := value.MarshalJSON()
+		if err != nil {
+			return "", err
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return "", err
+		}
+		switch decoded := decoded.(type) {
+		case nil:
+			return "", nil
+		case string:
+			return decoded, nil
+		default:
+			return string(data), nil
+		}
+	case fmt.Stringer:
+		return value.String(), nil
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		data, err := json.Marshal(value)
+		return string(data), err
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+type parquetEncoder struct {
+	writer *writer.JSONWriter
+}
+
+func newParquetEncoder(w io.Writer, schema bigquery.Schema) (*parquetEncoder, error) {
+	parquetSchema, err := json.Marshal(parquetGroup("parquet_go_root", "REQUIRED", schema))
+	if err != nil {
+		return nil, err
+	}
+	jsonWriter, err := writer.NewJSONWriterFromWriter(string(parquetSchema), w, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetEncoder{writer: jsonWriter}, nil
+}
+
+func (e *parquetEncoder) Encode(values map[string]bigquery.Value) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return e.writer.Write(string(data))
+}
+
+func (e *parquetEncoder) Close() error {
+	return e.writer.WriteStop()
+}
+
+// parquetField is a field of a parquet-go JSON schema.
+type parquetField struct {
+	Tag    string
+	Fields []*parquetField `json:",omitempty"`
+}
+
+func parquetGroup(name string, repetition string, schema bigquery.Schema) *parquetField {
+	group := &parquetField{Tag: parquetTag("name="+name, "repetitiontype="+repetition)}
+	for _, field := range schema {
+		group.Fields = append(group.Fields, parquetSchemaField(field))
+	}
+	return group
+}
+
+func parquetSchemaField(field *bigquery.FieldSchema) *parquetField {
+	repetition := "OPTIONAL"
+	if field.Repeated {
+		repetition = "REPEATED"
+	}
+	switch field.Type {
+	case bigquery.RecordFieldType:
+		return parquetGroup(field.Name, repetition, field.Schema)
+	case bigquery.IntegerFieldType:
+		return &parquetField{Tag: parquetTag("name="+field.Name, "type=INT64", "repetitiontype="+repetition)}
+	case bigquery.FloatFieldType:
+		return &parquetField{Tag: parquetTag("name="+field.Name, "type=DOUBLE", "repetitiontype="+repetition)}
+	case bigquery.BooleanFieldType:
+		return &parquetField{Tag: parquetTag("name="+field.Name, "type=BOOLEAN", "repetitiontype="+repetition)}
+	default:
+		return &parquetField{
+			Tag: parquetTag("name="+field.Name, "type=BYTE_ARRAY", "convertedtype=UTF8", "repetitiontype="+repetition),
+		}
+	}
+}
+
+func parquetTag(parts ...string) string {
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,200 @@
+package filesink
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+type testItem struct {
+	ID    string `bigquery:"id"`
+	Count int64  `bigquery:"count"`
+}
+
+type testProfile struct {
+	Title string `bigquery:"title"`
+	Admin bool   `bigquery:"admin"`
+}
+
+type testRow struct {
+	Name    string              `bigquery:"name"`
+	Count   int64               `bigquery:"count"`
+	Score   float64             `bigquery:"score"`
+	Active  bool                `bigquery:"active"`
+	Date    civil.Date          `bigquery:"date"`
+	Created time.Time           `bigquery:"created"`
+	Note    bigquery.NullString `bigquery:"note"`
+	Tags    []string            `bigquery:"tags"`
+	Profile testProfile         `bigquery:"profile"`
+	Items   []testItem          `bigquery:"items"`
+}
+
+func testRows(t *testing.T) (bigquery.Schema, []map[string]bigquery.Value) {
+	t.Helper()
+	schema, err := bigquery.InferSchema(testRow{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []testRow{
+		{
+			Name:    "general, \"quoted\"",
+			Count:   42,
+			Score:   1.5,
+			Active:  true,
+			Date:    civil.Date{Year: 2022, Month: 10, Day: 17},
+			Created: time.Date(2022, 10, 17, 12, 30, 0, 500, time.UTC),
+			Note:    bigquery.NullString{StringVal: "note", Valid: true},
+			Tags:    []string{"a", "b"},
+			Profile: testProfile{Title: "engineer", Admin: true},
+			Items:   []testItem{{ID: "x", Count: 1}, {ID: "y", Count: 2}},
+		},
+		{
+			Name:    "empty",
+			Date:    civil.Date{Year: 2022, Month: 10, Day: 17},
+			Created: time.Date(2022, 10, 17, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	values := make([]map[string]bigquery.Value, 0, len(rows))
+	for i := range rows {
+		saved, _, err := (&bigquery.StructSaver{Schema: schema, Struct: &rows[i]}).Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, saved)
+	}
+	return schema, values
+}
+
+func encode(t *testing.T, format Format, schema bigquery.Schema, rows []map[string]bigquery.Value) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	e, err := newEncoder(format, &b, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := e.Encode(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// normalize returns a value as decoded from JSON, for comparing values regardless of their Go types.
+func normalize(t *testing.T, value interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		t.Fatal(err)
+	}
+	return normalized
+}
+
+func TestEncoder_NDJSON(t *testing.T) {
+	schema, rows := testRows(t)
+	decoder := json.NewDecoder(bytes.NewReader(encode(t, FormatNDJSON, schema, rows)))
+	for i, row := range rows {
+		var got map[string]interface{}
+		if err := decoder.Decode(&got); err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+		if want := normalize(t, row); !reflect.DeepEqual(normalize(t, got), want) {
+			t.Errorf("row %d: got %v, want %v", i, got, want)
+		}
+	}
+	if decoder.More() {
+		t.Error("got more rows than encoded")
+	}
+}
+
+func TestEncoder_CSV(t *testing.T) {
+	schema, rows := testRows(t)
+	records, err := csv.NewReader(bytes.NewReader(encode(t, FormatCSV, schema, rows))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"name", "count", "score", "active", "date", "created", "note", "tags", "profile", "items"},
+		{
+			"general, \"quoted\"",
+			"42",
+			"1.5",
+			"true",
+			"2022-10-17",
+			"2022-10-17T12:30:00.0000005Z",
+			"note",
+			`["a","b"]`,
+			`{"admin":true,"title":"engineer"}`,
+			`[{"count":1,"id":"x"},{"count":2,"id":"y"}]`,
+		},
+		{"empty", "0", "0", "false", "2022-10-17", "2022-10-17T00:00:00Z", "", "", `{"admin":false,"title":""}`, ""},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %q, want %q", records, want)
+	}
+}
+
+func TestEncoder_Parquet(t *testing.T) {
+	schema, rows := testRows(t)
+	file, err := buffer.NewBufferFile(encode(t, FormatParquet, schema, rows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.NewParquetReader(file, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.ReadStop()
+	if got, want := int(r.GetNumRows()), len(rows); got != want {
+		t.Fatalf("got %d rows, want %d", got, want)
+	}
+	read, err := r.ReadByNumber(len(rows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range rows {
+		// The rows are read into structs with exported field names, and with nil values for null fields.
+		got, want := lowerKeys(normalize(t, read[i])), lowerKeys(normalize(t, row))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("row %d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+// lowerKeys returns a normalized value with lower case map keys, and without map entries with nil values.
+func lowerKeys(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		lowered := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			if v != nil {
+				lowered[strings.ToLower(k)] = lowerKeys(v)
+			}
+		}
+		return lowered
+	case []interface{}:
+		lowered := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			lowered = append(lowered, lowerKeys(v))
+		}
+		return lowered
+	default:
+		return value
+	}
+}
@@ -0,0 +1,347 @@
+package filesink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+// Sink writes each table to a file on the local filesystem, at <dir>/<table>/<date>.<format>.
+//
+// Files are written to a temporary file next to their destination, and moved into place when the job is committed.
+type Sink struct {
+	Config    Config
+	JobConfig bigqueryapi.JobConfig
+	Logger    *zap.Logger
+
+	mu        sync.Mutex
+	files     map[string]*tableFile
+	rowCounts map[string]int
+}
+
+// tableFile is a file that the rows of a table are being encoded into.
+type tableFile struct {
+	row     tables.Row
+	path    string
+	file    *os.File
+	encoder encoder
+}
+
+// EnsureTables creates a new file for each table.
+// Files that already exist are handled according to the configured conflict policy of the job.
+func (s *Sink) EnsureTables(_ context.Context) error {
+	s.Logger.Info("ensuring tables", zap.String("dir", s.Config.Dir), zap.String("format", string(s.Config.Format)))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, row := range s.JobConfig.ExportedTables() {
+		if err := s.createFile(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResumeTables fails, since the files of an interrupted job are not kept.
+func (s *Sink) ResumeTables(_ context.Context) error {
+	return fmt.Errorf("resume tables: not supported by the file sink")
+}
+
+// Flush fails, since rows are only written durably when the job is committed.
+func (s *Sink) Flush(_ context.Context) error {
+	return fmt.Errorf("flush: not supported by the file sink")
+}
+
+// PutUsers writes an array of slack.User to the corresponding file.
+func (s *Sink) PutUsers(_ context.Context, users []slack.User) error {
+	return s.write(&tables.UsersRow{}, tables.NewUsersRows(s.JobConfig.Snapshot(), users))
+}
+
+// PutUserGroups writes an array of slack.UserGroup to the corresponding file.
+func (s *Sink) PutUserGroups(_ context.Context, usergroups []slack.UserGroup) error {
+	return s.write(&tables.UserGroupsRow{}, tables.NewUserGroupsRows(s.JobConfig.Snapshot(), usergroups))
+}
+
+// PutChannels writes an array of slack.Channel to the corresponding file.
+func (s *Sink) PutChannels(_ context.Context, channels []slack.Channel) error {
+	return s.write(&tables.ChannelsRow{}, tables.NewChannelsRows(s.JobConfig.Snapshot(), channels))
+}
+
+// PutChannelMembers writes an array of channel members to the corresponding file.
+func (s *Sink) PutChannelMembers(_ context.Context, channel *slack.Channel, members []string) error {
+	return s.write(&tables.ChannelMembersRow{}, tables.NewChannelMembersRows(s.JobConfig.Snapshot(), channel, members))
+}
+
+// PutFiles writes an array of slack.File to the corresponding file.
+func (s *Sink) PutFiles(_ context.Context, files []slack.File) error {
+	return s.write(&tables.FilesRow{}, tables.NewFilesRows(s.JobConfig.Snapshot(), files))
+}
+
+// PutMessages writes an array of slack.Message posted in a channel to the corresponding file.
+func (s *Sink) PutMessages(_ context.Context, channel *slack.Channel, messages []slack.Message) error {
+	return s.write(&tables.MessagesRow{}, tables.NewMessagesRows(s.JobConfig.Snapshot(), channel, messages))
+}
+
+// PutReactions writes the reactions to an array of slack.Message posted in a channel to the corresponding file.
+func (s *Sink) PutReactions(_ context.Context, channel *slack.Channel, messages []slack.Message) error {
+	return s.write(&tables.ReactionsRow{}, tables.NewReactionsRows(s.JobConfig.Snapshot(), channel, messages))
+}
+
+// PutPins writes the items pinned to a channel to the corresponding file.
+func (s *Sink) PutPins(_ context.Context, channel *slack.Channel, items []tables.PinnedItem) error {
+	return s.write(&tables.PinsRow{}, tables.NewPinsRows(s.JobConfig.Snapshot(), channel, items))
+}
+
+// PutBookmarks writes the bookmarks of a channel to the corresponding file.
+func (s *Sink) PutBookmarks(_ context.Context, channel *slack.Channel, bookmarks []slack.Bookmark) error {
+	return s.write(&tables.BookmarksRow{}, tables.NewBookmarksRows(s.JobConfig.Snapshot(), channel, bookmarks))
+}
+
+// PutDirectConversation writes a direct conversation and its members to the corresponding file.
+func (s *Sink) PutDirectConversation(_ context.Context, conversation *slack.Channel, members []string) error {
+	return s.write(
+		&tables.DirectConversationsRow{},
+		tables.NewDirectConversationsRows(s.JobConfig.Snapshot(), conversation, members),
+	)
+}
+
+// PutEmoji writes the custom emoji of the workspace to the corresponding file.
+func (s *Sink) PutEmoji(_ context.Context, emoji map[string]string) error {
+	return s.write(&tables.EmojiRow{}, tables.NewEmojiRows(s.JobConfig.Snapshot(), emoji))
+}
+
+// PutTeam writes the workspace, its custom profile field definitions and the ID of its enterprise organization to
+// the corresponding file.
+func (s *Sink) PutTeam(
+	_ context.Context,
+	team *slack.TeamInfo,
+	profile *slack.TeamProfile,
+	enterpriseID string,
+) error {
+	return s.write(&tables.TeamRow{}, tables.NewTeamRows(s.JobConfig.Snapshot(), team, profile, enterpriseID))
+}
+
+// PutUserProfileFields writes the custom profile fields of a user, joined to their definitions in the team profile,
+// to the corresponding file.
+func (s *Sink) PutUserProfileFields(
+	_ context.Context,
+	userID string,
+	profile *slack.UserProfile,
+	teamProfile *slack.TeamProfile,
+) error {
+	return s.write(
+		&tables.UserProfileFieldsRow{},
+		tables.NewUserProfileFieldsRows(s.JobConfig.Snapshot(), userID, profile, teamProfile),
+	)
+}
+
+// PutJobRun appends a record of the job run to <dir>/job_runs.ndjson, which is shared by all jobs.
+// Job runs are always written as newline-delimited JSON, since they are appended to across jobs.
+func (s *Sink) PutJobRun(_ context.Context, run *tables.JobRunsRow) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put job run: %w", err)
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.Config.Dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(s.Config.Dir, run.TableName()+FormatNDJSON.extension())
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	values, _, err := run.ValueSaver(s.JobConfig.ID).Save()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := (&ndjsonEncoder{encoder: json.NewEncoder(file)}).Encode(values); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LastFilesWindow returns the files window of the latest succeeded run of the org whose window ended before the job
+// date, or nil if there is none. Job runs are read from <dir>/job_runs.ndjson.
+func (s *Sink) LastFilesWindow(_ context.Context) (_ *tables.FilesWindow, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("get last files window: %w", err)
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := filepath.Join(s.Config.Dir, (&tables.JobRunsRow{}).TableName()+FormatNDJSON.extension())
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	before := s.JobConfig.Date.In(time.UTC)
+	var last *tables.FilesWindow
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var run struct {
+			Org              string                 `json:"org"`
+			Status           string                 `json:"status"`
+			FilesFrom        bigquery.NullTimestamp `json:"files_from"`
+			FilesTo          bigquery.NullTimestamp `json:"files_to"`
+			FilesRefreshedAt bigquery.NullTimestamp `json:"files_refreshed_at"`
+		}
+		if err := decoder.Decode(&run); err != nil {
+			return nil, err
+		}
+		if run.Org != s.JobConfig.Org || run.Status != tables.JobStatusSucceeded {
+			continue
+		}
+		if !run.FilesTo.Valid || !run.FilesTo.Timestamp.Before(before) {
+			continue
+		}
+		if last == nil || run.FilesTo.Timestamp.After(last.FilesTo.Timestamp) {
+			last = &tables.FilesWindow{
+				FilesFrom:        run.FilesFrom,
+				FilesTo:          run.FilesTo,
+				FilesRefreshedAt: run.FilesRefreshedAt,
+			}
+		}
+	}
+	return last, nil
+}
+
+// RowCounts returns the number of rows written to each table by the job.
+func (s *Sink) RowCounts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int, len(s.rowCounts))
+	for table, count := range s.rowCounts {
+		counts[table] = count
+	}
+	return counts
+}
+
+// Commit finishes the files of the job and moves them into place.
+func (s *Sink) Commit(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Logger.Info("committing tables")
+	for name, f := range s.files {
+		if err := f.close(); err != nil {
+			return fmt.Errorf("commit %s: %w", name, err)
+		}
+		if err := os.Rename(f.file.Name(), f.path); err != nil {
+			return fmt.Errorf("commit %s: %w", name, err)
+		}
+		delete(s.files, name)
+	}
+	return nil
+}
+
+// Close removes the files of the job that have not been committed.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, f := range s.files {
+		if err := f.file.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			return err
+		}
+		if err := os.Remove(f.file.Name()); err != nil {
+			return err
+		}
+		delete(s.files, name)
+	}
+	return nil
+}
+
+func (s *Sink) createFile(row tables.Row) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("create file for %s: %w", row.TableName(), err)
+		}
+	}()
+	dir := filepath.Join(s.Config.Dir, row.TableName())
+	path := filepath.Join(dir, s.JobConfig.Date.String()+s.Config.Format.extension())
+	if _, err := os.Stat(path); err == nil {
+		switch s.JobConfig.ConflictPolicy {
+		case bigqueryapi.ConflictPolicyTruncate, bigqueryapi.ConflictPolicySwap:
+			s.Logger.Info("replacing existing file", zap.String("path", path))
+		default:
+			return fmt.Errorf("file already exists: %s", path)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	encoder, err := newEncoder(s.Config.Format, file, row.TableMetadata().Schema)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if s.files == nil {
+		s.files = make(map[string]*tableFile)
+	}
+	s.files[row.TableName()] = &tableFile{row: row, path: path, file: file, encoder: encoder}
+	return nil
+}
+
+func (s *Sink) write(table tables.Row, rows []tables.Row) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("write %s: %w", table.TableName(), err)
+		}
+	}()
+	if len(rows) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[table.TableName()]
+	if !ok {
+		return fmt.Errorf("no file for table")
+	}
+	s.Logger.Debug("writing "+table.TableName(), zap.Int("count", len(rows)))
+	if s.rowCounts == nil {
+		s.rowCounts = make(map[string]int)
+	}
+	s.rowCounts[table.TableName()] += len(rows)
+	for _, row := range rows {
+		values, _, err := row.ValueSaver(s.JobConfig.ID).Save()
+		if err != nil {
+			return err
+		}
+		if err := f.encoder.Encode(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *tableFile) close() error {
+	if err := f.encoder.Close(); err != nil {
+		return err
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,36 @@
+package memsink
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+)
+
+// AlreadyExistsError returns the error returned by BigQuery when creating a table that already exists.
+func AlreadyExistsError(tableID string) error {
+	return &googleapi.Error{
+		Code:    http.StatusConflict,
+		Message: fmt.Sprintf("Already Exists: Table %s", tableID),
+		Errors:  []googleapi.ErrorItem{{Reason: "duplicate", Message: fmt.Sprintf("Already Exists: Table %s", tableID)}},
+	}
+}
+
+// NotFoundError returns the error returned by BigQuery when writing to a table that does not exist.
+func NotFoundError(tableID string) error {
+	return &googleapi.Error{
+		Code:    http.StatusNotFound,
+		Message: fmt.Sprintf("Not found: Table %s", tableID),
+		Errors:  []googleapi.ErrorItem{{Reason: "notFound", Message: fmt.Sprintf("Not found: Table %s", tableID)}},
+	}
+}
+
+// QuotaExceededError returns the error returned by BigQuery when a quota of the project is exceeded.
+func QuotaExceededError() error {
+	const message = "Quota exceeded: Your table exceeded quota for imports or query appends per table."
+	return &googleapi.Error{
+		Code:    http.StatusForbidden,
+		Message: message,
+		Errors:  []googleapi.ErrorItem{{Reason: "quotaExceeded", Message: message}},
+	}
+}
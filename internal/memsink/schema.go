@@ -0,0 +1,148 @@
+package memsink
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+)
+
+// goldenSchemas holds the expected schema of each table, in the JSON format of the bq command-line tool.
+// Rows are validated against the golden schemas rather than the schemas inferred from the row types, so that a change
+// to a row type that changes the schema of its table fails until the golden schema is deliberately updated.
+//
+//go:embed schemas/*.json
+var goldenSchemas embed.FS
+
+// GoldenSchemaPath returns the path of the golden schema of a table, relative to the package directory.
+func GoldenSchemaPath(tableName string) string {
+	return "schemas/" + tableName + ".json"
+}
+
+// goldenSchema returns the golden schema of the table of the row type, or an error if the schema of the row type
+// does not match it.
+func goldenSchema(row tables.Row) (bigquery.Schema, error) {
+	data, err := goldenSchemas.ReadFile(GoldenSchemaPath(row.TableName()))
+	if err != nil {
+		return nil, fmt.Errorf("golden schema of %s: %w", row.TableName(), err)
+	}
+	schema, err := bigquery.SchemaFromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("golden schema of %s: %w", row.TableName(), err)
+	}
+	golden, err := schema.ToJSONFields()
+	if err != nil {
+		return nil, err
+	}
+	inferred, err := row.TableMetadata().Schema.ToJSONFields()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(golden, inferred) {
+		return nil, fmt.Errorf("schema of %s does not match its golden schema", row.TableName())
+	}
+	return schema, nil
+}
+
+// tableMetadata returns the metadata of the table of the row type, with its golden schema.
+func tableMetadata(row tables.Row) (*bigquery.TableMetadata, error) {
+	schema, err := goldenSchema(row)
+	if err != nil {
+		return nil, err
+	}
+	metadata := row.TableMetadata()
+	metadata.Schema = schema
+	return metadata, nil
+}
+
+// validateRow returns an error if the values of a row do not match a schema.
+func validateRow(schema bigquery.Schema, values map[string]bigquery.Value) error {
+	fields := make(map[string]*bigquery.FieldSchema, len(schema))
+	for _, field := range schema {
+		fields[field.Name] = field
+		if field.Required && values[field.Name] == nil {
+			return fmt.Errorf("missing required field: %s", field.Name)
+		}
+	}
+	for name, value := range values {
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("no such field: %s", name)
+		}
+		if err := validateField(field, value); err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateField(field *bigquery.FieldSchema, value bigquery.Value) error {
+	if value == nil {
+		return nil
+	}
+	if !field.Repeated {
+		return validateValue(field, value)
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("repeated field has non-repeated value of type %T", value)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := validateValue(field, v.Index(i).Interface()); err != nil {
+			return fmt.Errorf("index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateValue(field *bigquery.FieldSchema, value bigquery.Value) error {
+	var ok bool
+	switch field.Type {
+	case bigquery.StringFieldType:
+		switch value.(type) {
+		case string, bigquery.NullString:
+			ok = true
+		}
+	case bigquery.IntegerFieldType:
+		switch value.(type) {
+		case int, int8, int16, int32, int64, uint8, uint16, uint32, bigquery.NullInt64:
+			ok = true
+		}
+	case bigquery.FloatFieldType:
+		switch value.(type) {
+		case float32, float64, bigquery.NullFloat64:
+			ok = true
+		}
+	case bigquery.BooleanFieldType:
+		switch value.(type) {
+		case bool, bigquery.NullBool:
+			ok = true
+		}
+	case bigquery.TimestampFieldType:
+		switch value.(type) {
+		case time.Time, bigquery.NullTimestamp:
+			ok = true
+		}
+	case bigquery.DateFieldType:
+		switch value.(type) {
+		case civil.Date, bigquery.NullDate:
+			ok = true
+		}
+	case bigquery.RecordFieldType:
+		if record, isRecord := value.(map[string]bigquery.Value); isRecord {
+			return validateRow(field.Schema, record)
+		}
+	default:
+		// Other types are not used by the tables of the importer, and are not validated.
+		ok = true
+	}
+	if !ok {
+		return fmt.Errorf("value of type %T does not match field type %s", value, field.Type)
+	}
+	return nil
+}
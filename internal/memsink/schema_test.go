@@ -0,0 +1,46 @@
+package memsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGoldenSchemas(t *testing.T) {
+	for _, row := range append(tables.AllRows(), &tables.JobRunsRow{}) {
+		row := row
+		t.Run(row.TableName(), func(t *testing.T) {
+			fields, err := row.TableMetadata().Schema.ToJSONFields()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want bytes.Buffer
+			if err := json.Indent(&want, fields, "", "  "); err != nil {
+				t.Fatal(err)
+			}
+			want.WriteString("\n")
+			path := GoldenSchemaPath(row.TableName())
+			if *update {
+				if err := os.WriteFile(path, want.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("schema of %s does not match %s, update it with -update if intended", row.TableName(), path)
+			}
+			if _, err := goldenSchema(row); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+// Package memsink provides an in-memory sink, for running the importer without GCP.
+package memsink
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+// Sink records the rows of each table in memory, validating them against the golden schema of their table.
+//
+// Tables follow the write path of BigQuery: tables are created by EnsureTables according to the conflict policy of
+// the job, rows are staged until the job is committed, and job runs are inserted immediately.
+// Tables are identified by their date-sharded table ID, regardless of the partitioning of the job.
+type Sink struct {
+	JobConfig bigqueryapi.JobConfig
+	Logger    *zap.Logger
+	// Discard makes the sink validate and count the exported rows without keeping them, e.g. for a dry run of a job
+	// that exports a large workspace. Job runs are kept regardless.
+	Discard bool
+
+	mu        sync.Mutex
+	tables    map[string]*Table
+	staged    map[string]*Table
+	faults    map[string][]error
+	rowCounts map[string]int
+}
+
+// Table is a table recorded by the sink.
+type Table struct {
+	Metadata *bigquery.TableMetadata
+	Rows     []Row
+}
+
+// Row is a row of a Table.
+type Row struct {
+	InsertID string
+	Values   map[string]bigquery.Value
+}
+
+// AddTable adds an existing table to the sink, e.g. to simulate a table left by a previous job.
+func (s *Sink) AddTable(tableID string, table *Table) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tables == nil {
+		s.tables = make(map[string]*Table)
+	}
+	s.tables[tableID] = table.clone()
+}
+
+// Table returns a copy of a committed table.
+func (s *Sink) Table(tableID string) (*Table, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	table, ok := s.tables[tableID]
+	if !ok {
+		return nil, false
+	}
+	return table.clone(), true
+}
+
+// TableIDs returns the IDs of the committed tables.
+func (s *Sink) TableIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tableIDs := make([]string, 0, len(s.tables))
+	for tableID := range s.tables {
+		tableIDs = append(tableIDs, tableID)
+	}
+	return tableIDs
+}
+
+// Inject makes the following calls to a method of the sink, e.g. "PutMessages", fail with errs, one error per call.
+// See AlreadyExistsError and QuotaExceededError for errors returned by BigQuery.
+func (s *Sink) Inject(method string, errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.faults == nil {
+		s.faults = make(map[string][]error)
+	}
+	s.faults[method] = append(s.faults[method], errs...)
+}
+
+// EnsureTables creates new staging tables.
+// Tables that already exist are handled according to the configured conflict policy of the job.
+func (s *Sink) EnsureTables(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.nextFault("EnsureTables"); err != nil {
+		return err
+	}
+	s.Logger.Info("ensuring tables", zap.String("conflictPolicy", string(s.JobConfig.ConflictPolicy)))
+	for _, row := range s.JobConfig.ExportedTables() {
+		tableID := row.TableID(s.JobConfig.Date)
+		if _, ok := s.tables[tableID]; ok {
+			switch s.JobConfig.ConflictPolicy {
+			case bigqueryapi.ConflictPolicyTruncate,
+				bigqueryapi.ConflictPolicyAppend,
+				bigqueryapi.ConflictPolicySwap:
+				s.Logger.Info("table already exists", zap.String("tableID", tableID))
+			default:
+				return fmt.Errorf("create table %s: %w", tableID, AlreadyExistsError(tableID))
+			}
+		}
+		metadata, err := tableMetadata(row)
+		if err != nil {
+			return err
+		}
+		if s.staged == nil {
+			s.staged = make(map[string]*Table)
+		}
+		s.staged[tableID] = &Table{Metadata: metadata}
+	}
+	return nil
+}
+
+// ResumeTables prepares the tables of an interrupted run of the job for writing more rows.
+// Staged tables are kept, and missing staged tables are created.
+func (s *Sink) ResumeTables(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.nextFault("ResumeTables"); err != nil {
+		return err
+	}
+	s.Logger.Info("resuming tables")
+	if s.staged == nil {
+		s.staged = make(map[string]*Table)
+	}
+	for _, row := range s.JobConfig.ExportedTables() {
+		tableID := row.TableID(s.JobConfig.Date)
+		if _, ok := s.staged[tableID]; !ok {
+			metadata, err := tableMetadata(row)
+			if err != nil {
+				return err
+			}
+			s.staged[tableID] = &Table{Metadata: metadata}
+		}
+	}
+	return nil
+}
+
+// Flush does nothing, since staged rows are kept in memory until Close.
+func (s *Sink) Flush(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextFault("Flush")
+}
+
+// PutUsers adds an array of slack.User to the corresponding table.
+func (s *Sink) PutUsers(_ context.Context, users []slack.User) error {
+	return s.put("PutUsers", &tables.UsersRow{}, tables.NewUsersRows(s.JobConfig.Snapshot(), users))
+}
+
+// PutUserGroups adds an array of slack.UserGroup to the corresponding table.
+func (s *Sink) PutUserGroups(_ context.Context, usergroups []slack.UserGroup) error {
+	return s.put("PutUserGroups", &tables.UserGroupsRow{}, tables.NewUserGroupsRows(s.JobConfig.Snapshot(), usergroups))
+}
+
+// PutChannels adds an array of slack.Channel to the corresponding table.
+func (s *Sink) PutChannels(_ context.Context, channels []slack.Channel) error {
+	return s.put("PutChannels", &tables.ChannelsRow{}, tables.NewChannelsRows(s.JobConfig.Snapshot(), channels))
+}
+
+// PutChannelMembers adds an array of channel members to the corresponding table.
+func (s *Sink) PutChannelMembers(_ context.Context, channel *slack.Channel, members []string) error {
+	return s.put(
+		"PutChannelMembers",
+		&tables.ChannelMembersRow{},
+		tables.NewChannelMembersRows(s.JobConfig.Snapshot(), channel, members),
+	)
+}
+
+// PutFiles adds an array of slack.File to the corresponding table.
+func (s *Sink) PutFiles(_ context.Context, files []slack.File) error {
+	return s.put("PutFiles", &tables.FilesRow{}, tables.NewFilesRows(s.JobConfig.Snapshot(), files))
+}
+
+// PutMessages adds an array of slack.Message posted in a channel to the corresponding table.
+func (s *Sink) PutMessages(_ context.Context, channel *slack.Channel, messages []slack.Message) error {
+	return s.put("PutMessages", &tables.MessagesRow{}, tables.NewMessagesRows(s.JobConfig.Snapshot(), channel, messages))
+}
+
+// PutReactions adds the reactions to an array of slack.Message posted in a channel to the corresponding table.
+func (s *Sink) PutReactions(_ context.Context, channel *slack.Channel, messages []slack.Message) error {
+	return s.put(
+		"PutReactions",
+		&tables.ReactionsRow{},
+		tables.NewReactionsRows(s.JobConfig.Snapshot(), channel, messages),
+	)
+}
+
+// PutPins adds the items pinned to a channel to the corresponding table.
+func (s *Sink) PutPins(_ context.Context, channel *slack.Channel, items []tables.PinnedItem) error {
+	return s.put("PutPins", &tables.PinsRow{}, tables.NewPinsRows(s.JobConfig.Snapshot(), channel, items))
+}
+
+// PutBookmarks adds the bookmarks of a channel to the corresponding table.
+func (s *Sink) PutBookmarks(_ context.Context, channel *slack.Channel, bookmarks []slack.Bookmark) error {
+	return s.put(
+		"PutBookmarks",
+		&tables.BookmarksRow{},
+		tables.NewBookmarksRows(s.JobConfig.Snapshot(), channel, bookmarks),
+	)
+}
+
+// PutDirectConversation adds a direct conversation and its members to the corresponding table.
+func (s *Sink) PutDirectConversation(_ context.Context, conversation *slack.Channel, members []string) error {
+	return s.put(
+		"PutDirectConversation",
+		&tables.DirectConversationsRow{},
+		tables.NewDirectConversationsRows(s.JobConfig.Snapshot(), conversation, members),
+	)
+}
+
+// PutEmoji adds the custom emoji of the workspace to the corresponding table.
+func (s *Sink) PutEmoji(_ context.Context, emoji map[string]string) error {
+	return s.put("PutEmoji", &tables.EmojiRow{}, tables.NewEmojiRows(s.JobConfig.Snapshot(), emoji))
+}
+
+// PutTeam adds the workspace, its custom profile field definitions and the ID of its enterprise organization to the
+// corresponding table.
+func (s *Sink) PutTeam(
+	_ context.Context,
+	team *slack.TeamInfo,
+	profile *slack.TeamProfile,
+	enterpriseID string,
+) error {
+	return s.put("PutTeam", &tables.TeamRow{}, tables.NewTeamRows(s.JobConfig.Snapshot(), team, profile, enterpriseID))
+}
+
+// PutUserProfileFields adds the custom profile fields of a user, joined to their definitions in the team profile, to
+// the corresponding table.
+func (s *Sink) PutUserProfileFields(
+	_ context.Context,
+	userID string,
+	profile *slack.UserProfile,
+	teamProfile *slack.TeamProfile,
+) error {
+	return s.put(
+		"PutUserProfileFields",
+		&tables.UserProfileFieldsRow{},
+		tables.NewUserProfileFieldsRows(s.JobConfig.Snapshot(), userID, profile, teamProfile),
+	)
+}
+
+// PutJobRun adds a record of the job run to the job runs table, which is shared by all jobs.
+// The record is inserted immediately, creating the table if it does not exist.
+func (s *Sink) PutJobRun(_ context.Context, run *tables.JobRunsRow) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put job run: %w", err)
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.nextFault("PutJobRun"); err != nil {
+		return err
+	}
+	tableID := run.TableID(s.JobConfig.Date)
+	if s.tables == nil {
+		s.tables = make(map[string]*Table)
+	}
+	table, ok := s.tables[tableID]
+	if !ok {
+		metadata, err := tableMetadata(run)
+		if err != nil {
+			return err
+		}
+		table = &Table{Metadata: metadata}
+		s.tables[tableID] = table
+	}
+	return table.insert(run.ValueSaver(s.JobConfig.ID), true)
+}
+
+// LastFilesWindow returns the files window of the latest succeeded run of the org whose window ended before the job
+// date, or nil if there is none.
+func (s *Sink) LastFilesWindow(_ context.Context) (*tables.FilesWindow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.nextFault("LastFilesWindow"); err != nil {
+		return nil, err
+	}
+	table, ok := s.tables[(&tables.JobRunsRow{}).TableID(s.JobConfig.Date)]
+	if !ok {
+		return nil, nil
+	}
+	before := s.JobConfig.Date.In(time.UTC)
+	var last *tables.FilesWindow
+	for _, row := range table.Rows {
+		if row.Values["org"] != s.JobConfig.Org || row.Values["status"] != tables.JobStatusSucceeded {
+			continue
+		}
+		window := tables.FilesWindow{
+			FilesFrom:        nullTimestamp(row.Values["files_from"]),
+			FilesTo:          nullTimestamp(row.Values["files_to"]),
+			FilesRefreshedAt: nullTimestamp(row.Values["files_refreshed_at"]),
+		}
+		if !window.FilesTo.Valid || !window.FilesTo.Timestamp.Before(before) {
+			continue
+		}
+		if last == nil || window.FilesTo.Timestamp.After(last.FilesTo.Timestamp) {
+			last = &window
+		}
+	}
+	return last, nil
+}
+
+// RowCounts returns the number of rows written to each table by the job.
+func (s *Sink) RowCounts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int, len(s.rowCounts))
+	for table, count := range s.rowCounts {
+		counts[table] = count
+	}
+	return counts
+}
+
+// Commit publishes the staged tables of the job.
+// Existing tables are appended to when using bigqueryapi.ConflictPolicyAppend, and replaced otherwise.
+func (s *Sink) Commit(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.nextFault("Commit"); err != nil {
+		return err
+	}
+	s.Logger.Info("committing tables")
+	if s.tables == nil {
+		s.tables = make(map[string]*Table)
+	}
+	for tableID, staged := range s.staged {
+		if existing, ok := s.tables[tableID]; ok && s.JobConfig.ConflictPolicy == bigqueryapi.ConflictPolicyAppend {
+			existing.Rows = append(existing.Rows, staged.Rows...)
+		} else {
+			s.tables[tableID] = staged
+		}
+		delete(s.staged, tableID)
+	}
+	return nil
+}
+
+// Close releases the sink. Staged tables are kept, so that an interrupted job can be resumed with ResumeTables.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextFault("Close")
+}
+
+// put validates rows and adds them to the staged table of the row type.
+func (s *Sink) put(method string, table tables.Row, rows []tables.Row) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("put %s: %w", table.TableName(), err)
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.nextFault(method); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	tableID := table.TableID(s.JobConfig.Date)
+	staged, ok := s.staged[tableID]
+	if !ok {
+		return NotFoundError(tableID)
+	}
+	s.Logger.Debug("inserting "+table.TableName(), zap.Int("count", len(rows)))
+	for _, row := range rows {
+		if err := staged.insert(row.ValueSaver(s.JobConfig.ID), !s.Discard); err != nil {
+			return err
+		}
+	}
+	if s.rowCounts == nil {
+		s.rowCounts = make(map[string]int)
+	}
+	s.rowCounts[table.TableName()] += len(rows)
+	return nil
+}
+
+// nextFault returns the next error injected for a method, if any.
+func (s *Sink) nextFault(method string) error {
+	errs := s.faults[method]
+	if len(errs) == 0 {
+		return nil
+	}
+	s.faults[method] = errs[1:]
+	return errs[0]
+}
+
+// nullTimestamp returns a timestamp value of a row, which is null if the column is missing.
+func nullTimestamp(value bigquery.Value) bigquery.NullTimestamp {
+	timestamp, _ := value.(bigquery.NullTimestamp)
+	return timestamp
+}
+
+// insert validates a row against the schema of the table and adds it to the table, unless the row is not kept.
+// The schema of tables created by the sink is their golden schema.
+func (t *Table) insert(valueSaver bigquery.ValueSaver, keep bool) error {
+	values, insertID, err := valueSaver.Save()
+	if err != nil {
+		return err
+	}
+	if err := validateRow(t.Metadata.Schema, values); err != nil {
+		return err
+	}
+	if !keep {
+		return nil
+	}
+	t.Rows = append(t.Rows, Row{InsertID: insertID, Values: values})
+	return nil
+}
+
+func (t *Table) clone() *Table {
+	return &Table{
+		Metadata: t.Metadata,
+		Rows:     append([]Row(nil), t.Rows...),
+	}
+}
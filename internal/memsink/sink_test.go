@@ -0,0 +1,320 @@
+package memsink
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/einride/bigquery-importer-slack/internal/api/bigqueryapi"
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+)
+
+const usersTableID = "users_20221017"
+
+func newSink(conflictPolicy bigqueryapi.ConflictPolicy) *Sink {
+	var config bigqueryapi.JobConfig
+	config.Org = "einride"
+	config.ID = uuid.New()
+	config.Date = civil.Date{Year: 2022, Month: 10, Day: 17}
+	config.ConflictPolicy = conflictPolicy
+	config.Tables = []string{"users"}
+	return &Sink{JobConfig: config, Logger: zap.NewNop()}
+}
+
+// runJob runs a job that exports users.
+func runJob(t *testing.T, s *Sink, users ...slack.User) error {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.EnsureTables(ctx); err != nil {
+		return err
+	}
+	if err := s.PutUsers(ctx, users); err != nil {
+		return err
+	}
+	return s.Commit(ctx)
+}
+
+func userIDs(t *testing.T, s *Sink) []string {
+	t.Helper()
+	table, ok := s.Table(usersTableID)
+	if !ok {
+		t.Fatal("users table was not committed")
+	}
+	ids := make([]string, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		ids = append(ids, row.Values["id"].(string))
+	}
+	return ids
+}
+
+func assertUserIDs(t *testing.T, s *Sink, want ...string) {
+	t.Helper()
+	got := userIDs(t, s)
+	if len(got) != len(want) {
+		t.Fatalf("got users %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got users %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSink_conflictPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		conflictPolicy bigqueryapi.ConflictPolicy
+		want           []string
+	}{
+		{conflictPolicy: bigqueryapi.ConflictPolicyTruncate, want: []string{"U2"}},
+		{conflictPolicy: bigqueryapi.ConflictPolicySwap, want: []string{"U2"}},
+		{conflictPolicy: bigqueryapi.ConflictPolicyAppend, want: []string{"U1", "U2"}},
+	} {
+		tt := tt
+		t.Run(string(tt.conflictPolicy), func(t *testing.T) {
+			s := newSink(tt.conflictPolicy)
+			if err := runJob(t, s, slack.User{ID: "U1"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := runJob(t, s, slack.User{ID: "U2"}); err != nil {
+				t.Fatal(err)
+			}
+			assertUserIDs(t, s, tt.want...)
+		})
+	}
+}
+
+func TestSink_conflictPolicyFail(t *testing.T) {
+	s := newSink(bigqueryapi.ConflictPolicyFail)
+	if err := runJob(t, s, slack.User{ID: "U1"}); err != nil {
+		t.Fatal(err)
+	}
+	err := runJob(t, s, slack.User{ID: "U2"})
+	var errAPI *googleapi.Error
+	if !errors.As(err, &errAPI) || errAPI.Code != http.StatusConflict {
+		t.Fatalf("got error %v, want an already exists error", err)
+	}
+	assertUserIDs(t, s, "U1")
+}
+
+func TestSink_notCommitted(t *testing.T) {
+	s := newSink(bigqueryapi.ConflictPolicyFail)
+	ctx := context.Background()
+	if err := s.EnsureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutUsers(ctx, []slack.User{{ID: "U1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Table(usersTableID); ok {
+		t.Error("users table is visible before the job is committed")
+	}
+	if got, want := s.RowCounts()["users"], 1; got != want {
+		t.Errorf("got %d rows, want %d", got, want)
+	}
+}
+
+func TestSink_Discard(t *testing.T) {
+	s := newSink(bigqueryapi.ConflictPolicyFail)
+	s.Discard = true
+	ctx := context.Background()
+	if err := s.EnsureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutUsers(ctx, []slack.User{{ID: "U1"}, {ID: "U2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertUserIDs(t, s)
+	if got, want := s.RowCounts()["users"], 2; got != want {
+		t.Errorf("got %d rows, want %d", got, want)
+	}
+}
+
+func TestSink_putBeforeEnsureTables(t *testing.T) {
+	s := newSink(bigqueryapi.ConflictPolicyFail)
+	err := s.PutUsers(context.Background(), []slack.User{{ID: "U1"}})
+	var errAPI *googleapi.Error
+	if !errors.As(err, &errAPI) || errAPI.Code != http.StatusNotFound {
+		t.Fatalf("got error %v, want a not found error", err)
+	}
+}
+
+func TestSink_Inject(t *testing.T) {
+	s := newSink(bigqueryapi.ConflictPolicyFail)
+	s.Inject("PutUsers", QuotaExceededError())
+	ctx := context.Background()
+	if err := s.EnsureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Only the next call fails.
+	if err := s.PutUsers(ctx, []slack.User{{ID: "U1"}}); err == nil {
+		t.Fatal("expected the injected error")
+	}
+	if err := s.PutUsers(ctx, []slack.User{{ID: "U2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertUserIDs(t, s, "U2")
+}
+
+func TestSink_ResumeTables(t *testing.T) {
+	s := newSink(bigqueryapi.ConflictPolicyFail)
+	ctx := context.Background()
+	if err := s.EnsureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutUsers(ctx, []slack.User{{ID: "U1"}}); err != nil {
+		t.Fatal(err)
+	}
+	// The rows staged by the interrupted run are kept.
+	if err := s.ResumeTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutUsers(ctx, []slack.User{{ID: "U2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertUserIDs(t, s, "U1", "U2")
+}
+
+// driftedUsersRow is a users row whose schema has a column that is missing from the golden schema of the table.
+type driftedUsersRow struct {
+	tables.UsersRow
+}
+
+func (r *driftedUsersRow) TableMetadata() *bigquery.TableMetadata {
+	metadata := r.UsersRow.TableMetadata()
+	metadata.Schema = append(metadata.Schema, &bigquery.FieldSchema{Name: "drifted", Type: bigquery.StringFieldType})
+	return metadata
+}
+
+func TestGoldenSchema_mismatch(t *testing.T) {
+	if _, err := goldenSchema(&driftedUsersRow{}); err == nil {
+		t.Fatal("expected an error for a schema that does not match the golden schema")
+	}
+}
+
+func TestValidateRow(t *testing.T) {
+	schema := bigquery.Schema{
+		{Name: "id", Type: bigquery.StringFieldType, Required: true},
+		{Name: "count", Type: bigquery.IntegerFieldType},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "profile", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "title", Type: bigquery.StringFieldType},
+		}},
+	}
+	for _, tt := range []struct {
+		name    string
+		values  map[string]bigquery.Value
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			values: map[string]bigquery.Value{
+				"id":      "U1",
+				"count":   int64(1),
+				"tags":    []string{"a"},
+				"profile": map[string]bigquery.Value{"title": "engineer"},
+			},
+		},
+		{name: "null", values: map[string]bigquery.Value{"id": "U1", "count": bigquery.NullInt64{}}},
+		{name: "missing required field", values: map[string]bigquery.Value{"count": int64(1)}, wantErr: true},
+		{name: "unknown field", values: map[string]bigquery.Value{"id": "U1", "name": "alice"}, wantErr: true},
+		{name: "wrong type", values: map[string]bigquery.Value{"id": "U1", "count": "1"}, wantErr: true},
+		{name: "not repeated", values: map[string]bigquery.Value{"id": "U1", "tags": "a"}, wantErr: true},
+		{
+			name:    "nested wrong type",
+			values:  map[string]bigquery.Value{"id": "U1", "profile": map[string]bigquery.Value{"title": 1}},
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRow(schema, tt.values); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSink_LastFilesWindow(t *testing.T) {
+	date := civil.Date{Year: 2022, Month: 10, Day: 17}
+	at := func(d civil.Date) bigquery.NullTimestamp {
+		return bigquery.NullTimestamp{Timestamp: d.In(time.UTC), Valid: true}
+	}
+	run := func(org, status string, filesTo bigquery.NullTimestamp) *tables.JobRunsRow {
+		var row tables.JobRunsRow
+		row.Org, row.JobID, row.SnapshotDate, row.Status = org, uuid.New().String(), date, status
+		row.FilesTo, row.FilesRefreshedAt = filesTo, filesTo
+		return &row
+	}
+	for _, tt := range []struct {
+		name string
+		runs []*tables.JobRunsRow
+		want *tables.FilesWindow
+	}{
+		{
+			name: "no runs",
+		},
+		{
+			name: "latest succeeded run",
+			runs: []*tables.JobRunsRow{
+				run("einride", tables.JobStatusSucceeded, at(date.AddDays(-2))),
+				run("einride", tables.JobStatusSucceeded, at(date.AddDays(-1))),
+				run("einride", tables.JobStatusFailed, at(date.AddDays(-1))),
+			},
+			want: &tables.FilesWindow{FilesTo: at(date.AddDays(-1)), FilesRefreshedAt: at(date.AddDays(-1))},
+		},
+		{
+			name: "ignores failed runs",
+			runs: []*tables.JobRunsRow{run("einride", tables.JobStatusFailed, at(date.AddDays(-1)))},
+		},
+		{
+			name: "ignores other orgs",
+			runs: []*tables.JobRunsRow{run("other", tables.JobStatusSucceeded, at(date.AddDays(-1)))},
+		},
+		{
+			name: "ignores runs of the job date and later",
+			runs: []*tables.JobRunsRow{
+				run("einride", tables.JobStatusSucceeded, at(date)),
+				run("einride", tables.JobStatusSucceeded, at(date.AddDays(1))),
+			},
+		},
+		{
+			name: "ignores runs without files window",
+			runs: []*tables.JobRunsRow{run("einride", tables.JobStatusSucceeded, bigquery.NullTimestamp{})},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSink(bigqueryapi.ConflictPolicyFail)
+			ctx := context.Background()
+			for _, run := range tt.runs {
+				if err := s.PutJobRun(ctx, run); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := s.LastFilesWindow(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package slackfake
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// handler returns the handler of a Web API method.
+func (s *Server) handler(method string) (func(url.Values) response, bool) {
+	switch method {
+	case "auth.test":
+		return s.authTest, true
+	case "team.info":
+		return s.teamInfo, true
+	case "team.profile.get":
+		return s.teamProfileGet, true
+	case "users.list":
+		return s.usersList, true
+	case "users.profile.get":
+		return s.usersProfileGet, true
+	case "usergroups.list":
+		return s.userGroupsList, true
+	case "conversations.list":
+		return s.conversationsList, true
+	case "conversations.members":
+		return s.conversationsMembers, true
+	case "conversations.history":
+		return s.conversationsHistory, true
+	case "conversations.replies":
+		return s.conversationsReplies, true
+	case "files.list":
+		return s.filesList, true
+	case "emoji.list":
+		return s.emojiList, true
+	case "pins.list":
+		return s.pinsList, true
+	case "bookmarks.list":
+		return s.bookmarksList, true
+	default:
+		return nil, false
+	}
+}
+
+func (s *Server) authTest(_ url.Values) response {
+	return response{
+		"ok":            true,
+		"team":          s.Data.Team.Name,
+		"team_id":       s.Data.Team.ID,
+		"enterprise_id": s.Data.EnterpriseID,
+	}
+}
+
+func (s *Server) teamInfo(_ url.Values) response {
+	return response{"ok": true, "team": s.Data.Team}
+}
+
+func (s *Server) teamProfileGet(_ url.Values) response {
+	return response{"ok": true, "profile": s.Data.TeamProfile}
+}
+
+func (s *Server) usersList(params url.Values) response {
+	start, end, nextCursor, ok := s.paginate(params, len(s.Data.Users))
+	if !ok {
+		return errorResponse("invalid_cursor")
+	}
+	return pageResponse("members", s.Data.Users[start:end], nextCursor)
+}
+
+// usersProfileGet returns the profile of a user, including its custom fields.
+func (s *Server) usersProfileGet(params url.Values) response {
+	for _, user := range s.Data.Users {
+		if user.ID == params.Get("user") {
+			return response{"ok": true, "profile": user.Profile}
+		}
+	}
+	return errorResponse("user_not_found")
+}
+
+func (s *Server) userGroupsList(_ url.Values) response {
+	return response{"ok": true, "usergroups": s.Data.UserGroups}
+}
+
+func (s *Server) conversationsList(params url.Values) response {
+	types := map[string]bool{"public_channel": true}
+	if params.Get("types") != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(params.Get("types"), ",") {
+			types[t] = true
+		}
+	}
+	excludeArchived := params.Get("exclude_archived") == "true"
+	channels := make([]slack.Channel, 0, len(s.Data.Channels))
+	for _, channel := range s.Data.Channels {
+		if !types[conversationType(&channel)] || (excludeArchived && channel.IsArchived) {
+			continue
+		}
+		channels = append(channels, channel)
+	}
+	start, end, nextCursor, ok := s.paginate(params, len(channels))
+	if !ok {
+		return errorResponse("invalid_cursor")
+	}
+	return pageResponse("channels", channels[start:end], nextCursor)
+}
+
+func (s *Server) conversationsMembers(params url.Values) response {
+	if !s.hasChannel(params.Get("channel")) {
+		return errorResponse("channel_not_found")
+	}
+	members := s.Data.ChannelMembers[params.Get("channel")]
+	start, end, nextCursor, ok := s.paginate(params, len(members))
+	if !ok {
+		return errorResponse("invalid_cursor")
+	}
+	return pageResponse("members", members[start:end], nextCursor)
+}
+
+// conversationsHistory returns the messages of a channel that are not thread replies, newest first.
+// Thread replies that were also sent to the channel are returned as well.
+func (s *Server) conversationsHistory(params url.Values) response {
+	if !s.hasChannel(params.Get("channel")) {
+		return errorResponse("channel_not_found")
+	}
+	messages := make([]slack.Message, 0, len(s.Data.Messages[params.Get("channel")]))
+	for _, message := range s.Data.Messages[params.Get("channel")] {
+		isReply := message.ThreadTimestamp != "" && message.ThreadTimestamp != message.Timestamp
+		if isReply && message.SubType != slack.MsgSubTypeThreadBroadcast {
+			continue
+		}
+		if !inRange(message.Timestamp, params.Get("oldest"), params.Get("latest")) {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return compareTimestamps(messages[i].Timestamp, messages[j].Timestamp) > 0
+	})
+	return s.messagesResponse(params, messages)
+}
+
+// conversationsReplies returns the parent message of a thread followed by its replies, oldest first.
+func (s *Server) conversationsReplies(params url.Values) response {
+	if !s.hasChannel(params.Get("channel")) {
+		return errorResponse("channel_not_found")
+	}
+	ts := params.Get("ts")
+	var parent *slack.Message
+	var replies []slack.Message
+	for _, message := range s.Data.Messages[params.Get("channel")] {
+		message := message
+		switch {
+		case message.Timestamp == ts:
+			parent = &message
+		case message.ThreadTimestamp == ts && inRange(message.Timestamp, params.Get("oldest"), params.Get("latest")):
+			replies = append(replies, message)
+		}
+	}
+	if parent == nil {
+		return errorResponse("thread_not_found")
+	}
+	sort.SliceStable(replies, func(i, j int) bool {
+		return compareTimestamps(replies[i].Timestamp, replies[j].Timestamp) < 0
+	})
+	return s.messagesResponse(params, append([]slack.Message{*parent}, replies...))
+}
+
+// filesList serves both the cursor and the page based pagination of files.list, filtering files by ts_from and ts_to.
+func (s *Server) filesList(params url.Values) response {
+	from, errFrom := strconv.ParseInt(params.Get("ts_from"), 10, 64)
+	to, errTo := strconv.ParseInt(params.Get("ts_to"), 10, 64)
+	files := make([]slack.File, 0, len(s.Data.Files))
+	for _, file := range s.Data.Files {
+		if (errFrom == nil && int64(file.Created) < from) || (errTo == nil && int64(file.Created) > to) {
+			continue
+		}
+		files = append(files, file)
+	}
+	if params.Get("cursor") != "" {
+		start, end, nextCursor, ok := s.paginate(params, len(files))
+		if !ok {
+			return errorResponse("invalid_cursor")
+		}
+		return pageResponse("files", files[start:end], nextCursor)
+	}
+	count := s.PageSize
+	if c, err := strconv.Atoi(params.Get("count")); err == nil && c > 0 && (count <= 0 || c < count) {
+		count = c
+	}
+	if count <= 0 {
+		count = len(files) + 1
+	}
+	page := 1
+	if p, err := strconv.Atoi(params.Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	pages := (len(files) + count - 1) / count
+	start, end := (page-1)*count, page*count
+	if start > len(files) {
+		start = len(files)
+	}
+	if end > len(files) {
+		end = len(files)
+	}
+	var nextCursor string
+	if end < len(files) {
+		nextCursor = strconv.Itoa(end)
+	}
+	body := pageResponse("files", files[start:end], nextCursor)
+	body["paging"] = response{"count": count, "total": len(files), "page": page, "pages": pages}
+	return body
+}
+
+func (s *Server) emojiList(_ url.Values) response {
+	return response{"ok": true, "emoji": s.Data.Emoji}
+}
+
+func (s *Server) pinsList(params url.Values) response {
+	if !s.hasChannel(params.Get("channel")) {
+		return errorResponse("channel_not_found")
+	}
+	return response{"ok": true, "items": s.Data.Pins[params.Get("channel")]}
+}
+
+func (s *Server) bookmarksList(params url.Values) response {
+	if !s.hasChannel(params.Get("channel_id")) {
+		return errorResponse("channel_not_found")
+	}
+	return response{"ok": true, "bookmarks": s.Data.Bookmarks[params.Get("channel_id")]}
+}
+
+func (s *Server) messagesResponse(params url.Values, messages []slack.Message) response {
+	start, end, nextCursor, ok := s.paginate(params, len(messages))
+	if !ok {
+		return errorResponse("invalid_cursor")
+	}
+	body := pageResponse("messages", messages[start:end], nextCursor)
+	body["has_more"] = nextCursor != ""
+	return body
+}
+
+func (s *Server) hasChannel(id string) bool {
+	for _, channel := range s.Data.Channels {
+		if channel.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate returns the bounds of the page of n items requested by the cursor and limit parameters,
+// and the cursor of the next page, which is empty on the last page.
+// Cursors are the offset of the first item of the page.
+func (s *Server) paginate(params url.Values, n int) (start, end int, nextCursor string, ok bool) {
+	if cursor := params.Get("cursor"); cursor != "" {
+		offset, err := strconv.Atoi(cursor)
+		if err != nil || offset < 0 || offset > n {
+			return 0, 0, "", false
+		}
+		start = offset
+	}
+	limit := s.PageSize
+	if l, err := strconv.Atoi(params.Get("limit")); err == nil && l > 0 && (limit <= 0 || l < limit) {
+		limit = l
+	}
+	end = n
+	if limit > 0 && start+limit < n {
+		end = start + limit
+		nextCursor = strconv.Itoa(end)
+	}
+	return start, end, nextCursor, true
+}
+
+func pageResponse(key string, items interface{}, nextCursor string) response {
+	return response{
+		"ok":                true,
+		key:                 items,
+		"response_metadata": response{"next_cursor": nextCursor},
+	}
+}
+
+func errorResponse(code string) response {
+	return response{"ok": false, "error": code}
+}
+
+// conversationType returns the type of a conversation, as used by the types parameter of conversations.list.
+func conversationType(channel *slack.Channel) string {
+	switch {
+	case channel.IsIM:
+		return "im"
+	case channel.IsMpIM:
+		return "mpim"
+	case channel.IsPrivate:
+		return "private_channel"
+	default:
+		return "public_channel"
+	}
+}
+
+// inRange reports whether a message timestamp is between oldest and latest, exclusive.
+// Empty bounds are unbounded.
+func inRange(ts, oldest, latest string) bool {
+	if oldest != "" && compareTimestamps(ts, oldest) <= 0 {
+		return false
+	}
+	if latest != "" && compareTimestamps(ts, latest) >= 0 {
+		return false
+	}
+	return true
+}
+
+// compareTimestamps compares two message timestamps on the form "1355517523.000005".
+func compareTimestamps(a, b string) int {
+	x, _ := strconv.ParseFloat(a, 64)
+	y, _ := strconv.ParseFloat(b, 64)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,169 @@
+// Package slackfake provides a fake Slack Web API server, for running the importer offline.
+package slackfake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/einride/bigquery-importer-slack/internal/tables"
+	"github.com/slack-go/slack"
+)
+
+// defaultPageSize is the maximum number of items returned per page by paginated methods.
+const defaultPageSize = 100
+
+// Data is the content of the workspace served by the fake server.
+type Data struct {
+	Team        slack.TeamInfo
+	TeamProfile slack.TeamProfile
+	// EnterpriseID is the ID of the Enterprise Grid organization of the workspace, if any.
+	EnterpriseID string
+	Users        []slack.User
+	UserGroups   []slack.UserGroup
+	Channels     []slack.Channel
+	// ChannelMembers are the IDs of the members of each channel, by channel ID.
+	ChannelMembers map[string][]string
+	Files          []slack.File
+	// Messages are the messages posted in each channel, including thread replies, by channel ID.
+	Messages map[string][]slack.Message
+	// Emoji are the custom emoji, mapped from their name to their URL or to "alias:<name>".
+	Emoji map[string]string
+	// Pins are the items pinned to each channel, by channel ID.
+	Pins map[string][]tables.PinnedItem
+	// Bookmarks are the bookmarks of each channel, by channel ID.
+	Bookmarks map[string][]slack.Bookmark
+}
+
+// Server is a fake Slack Web API server, serving the methods used by the importer from Data.
+//
+// Paginated methods return at most PageSize items per page. Errors and rate limits can be simulated with Inject.
+type Server struct {
+	// Data is the content of the workspace. It must not be modified while requests are served.
+	Data     Data
+	PageSize int
+
+	server *httptest.Server
+	mu     sync.Mutex
+	faults map[string][]Fault
+	calls  map[string]int
+}
+
+// Fault is an error response returned by a method instead of its result.
+type Fault struct {
+	// StatusCode is the HTTP status code of the response. When zero the response has status 200 and ok false.
+	StatusCode int
+	// RetryAfter is the value of the Retry-After header of the response, in whole seconds.
+	RetryAfter time.Duration
+	// Error is the error code of a response with ok false, e.g. "channel_not_found".
+	Error string
+}
+
+// RateLimited returns a Fault that rate limits a request, asking the client to retry after d.
+func RateLimited(d time.Duration) Fault {
+	return Fault{StatusCode: http.StatusTooManyRequests, RetryAfter: d}
+}
+
+// SlackError returns a Fault that fails a request with a Slack error code, e.g. "invalid_auth".
+func SlackError(code string) Fault {
+	return Fault{Error: code}
+}
+
+// StatusError returns a Fault that fails a request with an HTTP status code, e.g. 503.
+func StatusError(statusCode int) Fault {
+	return Fault{StatusCode: statusCode}
+}
+
+// NewServer starts a fake Slack Web API server serving data. Close the server when done.
+func NewServer(data Data) *Server {
+	s := &Server{
+		Data:     data,
+		PageSize: defaultPageSize,
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL returns the base URL of the Web API of the server, for use with slack.OptionAPIURL.
+func (s *Server) URL() string {
+	return s.server.URL + "/api/"
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Inject makes the following requests to a Web API method, e.g. "users.list", fail with faults, one fault per request.
+func (s *Server) Inject(method string, faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.faults == nil {
+		s.faults = make(map[string][]Fault)
+	}
+	s.faults[method] = append(s.faults[method], faults...)
+}
+
+// Calls returns the number of requests made to a Web API method, including failed requests.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	if fault, ok := s.nextFault(method); ok {
+		writeFault(w, fault)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFault(w, StatusError(http.StatusBadRequest))
+		return
+	}
+	handler, ok := s.handler(method)
+	if !ok {
+		writeJSON(w, errorResponse("unknown_method"))
+		return
+	}
+	writeJSON(w, handler(r.Form))
+}
+
+// nextFault counts a request to method and returns the next fault injected for it, if any.
+func (s *Server) nextFault(method string) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[method]++
+	faults := s.faults[method]
+	if len(faults) == 0 {
+		return Fault{}, false
+	}
+	s.faults[method] = faults[1:]
+	return faults[0], true
+}
+
+func writeFault(w http.ResponseWriter, fault Fault) {
+	if fault.RetryAfter > 0 || fault.StatusCode == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", strconv.Itoa(int(fault.RetryAfter/time.Second)))
+	}
+	if fault.StatusCode != 0 && fault.StatusCode != http.StatusOK {
+		w.WriteHeader(fault.StatusCode)
+		return
+	}
+	writeJSON(w, response{"ok": false, "error": fault.Error})
+}
+
+// response is the JSON body of a Web API response.
+type response map[string]interface{}
+
+func writeJSON(w http.ResponseWriter, body response) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,94 @@
+package tables
+
+import (
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+// BookmarksRow holds a bookmark of a channel. For field descriptions see the official documentation:
+// https://api.slack.com/methods/bookmarks.list
+//
+// Slack does not return the creator of a bookmark, only the user that last updated it.
+type BookmarksRow struct {
+	Snapshot
+	ID            string    `bigquery:"id"`
+	ChannelID     string    `bigquery:"channel_id"`
+	ChannelName   string    `bigquery:"channel_name"`
+	Type          string    `bigquery:"type"`
+	Title         string    `bigquery:"title"`
+	Link          string    `bigquery:"link"`
+	Emoji         string    `bigquery:"emoji"`
+	LastUpdatedBy string    `bigquery:"last_updated_by"`
+	Created       time.Time `bigquery:"created"`
+	Updated       time.Time `bigquery:"updated"`
+}
+
+var _ Row = &BookmarksRow{}
+
+func (b *BookmarksRow) TableName() string {
+	return "bookmarks"
+}
+
+func (b *BookmarksRow) TableID(date civil.Date) string {
+	return ShardedTableID(b.TableName(), date)
+}
+
+func (b *BookmarksRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
+	return &bigquery.StructSaver{
+		Schema:   b.Schema(),
+		InsertID: b.InsertID(jobID),
+		Struct:   b,
+	}
+}
+
+func (b *BookmarksRow) Schema() bigquery.Schema {
+	schema, _ := bigquery.InferSchema(b)
+	return schema
+}
+
+func (b *BookmarksRow) TableMetadata() *bigquery.TableMetadata {
+	return &bigquery.TableMetadata{
+		Description: "bookmarks holds the bookmarks of channels. For field descriptions see the official " +
+			"documentation: https://api.slack.com/methods/bookmarks.list",
+		Schema: b.Schema(),
+	}
+}
+
+func (b *BookmarksRow) InsertID(jobID uuid.UUID) string {
+	return strings.Join([]string{
+		jobID.String(),
+		b.ID,
+	}, "-")
+}
+
+// NewBookmarksRows returns a row for each slack.Bookmark of a channel.
+func NewBookmarksRows(snapshot Snapshot, channel *slack.Channel, bookmarks []slack.Bookmark) []Row {
+	rows := make([]Row, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		bookmark := bookmark
+		row := &BookmarksRow{
+			Snapshot:    snapshot,
+			ChannelID:   channel.ID,
+			ChannelName: channel.Name,
+		}
+		row.UnmarshalSlackBookmark(&bookmark)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (b *BookmarksRow) UnmarshalSlackBookmark(sb *slack.Bookmark) {
+	b.ID = sb.ID
+	b.Type = sb.Type
+	b.Title = sb.Title
+	b.Link = sb.Link
+	b.Emoji = sb.Emoji
+	b.LastUpdatedBy = sb.LastUpdatedByUserID
+	b.Created = sb.Created.Time().UTC()
+	b.Updated = sb.Updated.Time().UTC()
+}
@@ -6,10 +6,12 @@ import (
 	"cloud.google.com/go/bigquery"
 	"cloud.google.com/go/civil"
 	"github.com/google/uuid"
+	"github.com/slack-go/slack"
 )
 
 // ChannelMembersRow is a connection between a channel and a member user.
 type ChannelMembersRow struct {
+	Snapshot
 	ChannelID   string `bigquery:"channel_id"`
 	ChannelName string `bigquery:"channel_name"`
 	Member      string `bigquery:"member"`
@@ -17,8 +19,12 @@ type ChannelMembersRow struct {
 
 var _ Row = &ChannelMembersRow{}
 
+func (c *ChannelMembersRow) TableName() string {
+	return "channel_members"
+}
+
 func (c *ChannelMembersRow) TableID(date civil.Date) string {
-	return "channel_members_" + strings.ReplaceAll(date.String(), "-", "")
+	return ShardedTableID(c.TableName(), date)
 }
 
 func (c *ChannelMembersRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
@@ -48,3 +54,17 @@ func (c *ChannelMembersRow) InsertID(jobID uuid.UUID) string {
 		c.Member,
 	}, "-")
 }
+
+// NewChannelMembersRows returns a row for each member of a slack.Channel.
+func NewChannelMembersRows(snapshot Snapshot, channel *slack.Channel, members []string) []Row {
+	rows := make([]Row, 0, len(members))
+	for _, member := range members {
+		rows = append(rows, &ChannelMembersRow{
+			Snapshot:    snapshot,
+			ChannelID:   channel.ID,
+			ChannelName: channel.Name,
+			Member:      member,
+		})
+	}
+	return rows
+}
@@ -12,16 +12,23 @@ import (
 // ChannelsRow follows the structure of the WebAPI. For field descriptions see the official
 // documentation: https://api.slack.com/types/channel
 type ChannelsRow struct {
-	Org       string  `bigquery:"org"`
-	ID        string  `bigquery:"id"`
-	Name      string  `bigquery:"name"`
-	Creator   string  `bigquery:"creator"`
-	Topic     Topic   `bigquery:"topic"`
-	Purpose   Purpose `bigquery:"purpose"`
-	IsChannel bool    `bigquery:"is_channel"`
-	IsGeneral bool    `bigquery:"is_general"`
-	Locale    string  `bigquery:"locale"`
-	Created   string  `bigquery:"created"`
+	Snapshot
+	ID          string  `bigquery:"id"`
+	Name        string  `bigquery:"name"`
+	Creator     string  `bigquery:"creator"`
+	Topic       Topic   `bigquery:"topic"`
+	Purpose     Purpose `bigquery:"purpose"`
+	IsChannel   bool    `bigquery:"is_channel"`
+	IsGeneral   bool    `bigquery:"is_general"`
+	IsArchived  bool    `bigquery:"is_archived"`
+	IsPrivate   bool    `bigquery:"is_private"`
+	IsShared    bool    `bigquery:"is_shared"`
+	IsExtShared bool    `bigquery:"is_ext_shared"`
+	IsOrgShared bool    `bigquery:"is_org_shared"`
+	NumMembers  int     `bigquery:"num_members"`
+	Unlinked    int     `bigquery:"unlinked"`
+	Locale      string  `bigquery:"locale"`
+	Created     string  `bigquery:"created"`
 }
 
 var _ Row = &ChannelsRow{}
@@ -37,8 +44,12 @@ type Purpose struct {
 	LastSet string `bigquery:"last_set"`
 }
 
+func (c *ChannelsRow) TableName() string {
+	return "channels"
+}
+
 func (c *ChannelsRow) TableID(date civil.Date) string {
-	return "channels_" + strings.ReplaceAll(date.String(), "-", "")
+	return ShardedTableID(c.TableName(), date)
 }
 
 func (c *ChannelsRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
@@ -69,6 +80,18 @@ func (c *ChannelsRow) InsertID(jobID uuid.UUID) string {
 	}, "-")
 }
 
+// NewChannelsRows returns a row for each slack.Channel.
+func NewChannelsRows(snapshot Snapshot, channels []slack.Channel) []Row {
+	rows := make([]Row, 0, len(channels))
+	for _, channel := range channels {
+		channel := channel
+		row := &ChannelsRow{Snapshot: snapshot}
+		row.UnmarshallSlackChannel(&channel)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 func (c *ChannelsRow) UnmarshallSlackChannel(sc *slack.Channel) {
 	if sc == nil {
 		*c = ChannelsRow{}
@@ -81,6 +104,13 @@ func (c *ChannelsRow) UnmarshallSlackChannel(sc *slack.Channel) {
 	c.Purpose.UnmarshallPurpose(&sc.Purpose)
 	c.IsChannel = sc.IsChannel
 	c.IsGeneral = sc.IsGeneral
+	c.IsArchived = sc.IsArchived
+	c.IsPrivate = sc.IsPrivate
+	c.IsShared = sc.IsShared
+	c.IsExtShared = sc.IsExtShared
+	c.IsOrgShared = sc.IsOrgShared
+	c.NumMembers = sc.NumMembers
+	c.Unlinked = sc.Unlinked
 	c.Locale = sc.Locale
 	c.Created = sc.Created.String()
 }
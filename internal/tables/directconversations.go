@@ -0,0 +1,95 @@
+package tables
+
+import (
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+const (
+	DirectConversationTypeIM   = "im"
+	DirectConversationTypeMPIM = "mpim"
+)
+
+// DirectConversationsRow holds the metadata and members of a direct message (im) or multi-party direct message (mpim)
+// conversation. The content of the conversations is not exported.
+type DirectConversationsRow struct {
+	Snapshot
+	ID         string   `bigquery:"id"`
+	Type       string   `bigquery:"type"`
+	Name       string   `bigquery:"name"`
+	User       string   `bigquery:"user"`
+	Creator    string   `bigquery:"creator"`
+	IsArchived bool     `bigquery:"is_archived"`
+	IsOpen     bool     `bigquery:"is_open"`
+	Members    []string `bigquery:"members"`
+	Created    string   `bigquery:"created"`
+}
+
+var _ Row = &DirectConversationsRow{}
+
+func (d *DirectConversationsRow) TableName() string {
+	return "direct_conversations"
+}
+
+func (d *DirectConversationsRow) TableID(date civil.Date) string {
+	return ShardedTableID(d.TableName(), date)
+}
+
+func (d *DirectConversationsRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
+	return &bigquery.StructSaver{
+		Schema:   d.Schema(),
+		InsertID: d.InsertID(jobID),
+		Struct:   d,
+	}
+}
+
+func (d *DirectConversationsRow) Schema() bigquery.Schema {
+	schema, _ := bigquery.InferSchema(d)
+	return schema
+}
+
+func (d *DirectConversationsRow) TableMetadata() *bigquery.TableMetadata {
+	return &bigquery.TableMetadata{
+		Description: "direct_conversations holds the metadata and members of direct message (im) and multi-party " +
+			"direct message (mpim) conversations. For field descriptions see the official documentation: " +
+			"https://api.slack.com/types/conversation",
+		Schema: d.Schema(),
+	}
+}
+
+func (d *DirectConversationsRow) InsertID(jobID uuid.UUID) string {
+	return strings.Join([]string{
+		jobID.String(),
+		d.ID,
+	}, "-")
+}
+
+// NewDirectConversationsRows returns a row for a direct conversation and its members.
+func NewDirectConversationsRows(snapshot Snapshot, conversation *slack.Channel, members []string) []Row {
+	row := &DirectConversationsRow{Snapshot: snapshot}
+	row.UnmarshalSlackConversation(conversation, members)
+	return []Row{row}
+}
+
+func (d *DirectConversationsRow) UnmarshalSlackConversation(sc *slack.Channel, members []string) {
+	if sc == nil {
+		*d = DirectConversationsRow{}
+		return
+	}
+	d.ID = sc.ID
+	d.Type = DirectConversationTypeIM
+	if sc.IsMpIM {
+		d.Type = DirectConversationTypeMPIM
+	}
+	d.Name = sc.Name
+	d.User = sc.User
+	d.Creator = sc.Creator
+	d.IsArchived = sc.IsArchived
+	d.IsOpen = sc.IsOpen
+	d.Members = members
+	d.Created = sc.Created.String()
+}
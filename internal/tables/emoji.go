@@ -0,0 +1,86 @@
+package tables
+
+import (
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+)
+
+// emojiAliasPrefix prefixes the value of an emoji that is an alias for another emoji, e.g. "alias:thumbsup".
+const emojiAliasPrefix = "alias:"
+
+// EmojiRow holds a custom emoji of the workspace. Emoji that are aliases for other emoji have no URL.
+// The uploader of an emoji is not returned by emoji.list, and is thereby not exported.
+type EmojiRow struct {
+	Snapshot
+	Name     string `bigquery:"name"`
+	URL      string `bigquery:"url"`
+	AliasFor string `bigquery:"alias_for"`
+}
+
+var _ Row = &EmojiRow{}
+
+func (e *EmojiRow) TableName() string {
+	return "emoji"
+}
+
+func (e *EmojiRow) TableID(date civil.Date) string {
+	return ShardedTableID(e.TableName(), date)
+}
+
+func (e *EmojiRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
+	return &bigquery.StructSaver{
+		Schema:   e.Schema(),
+		InsertID: e.InsertID(jobID),
+		Struct:   e,
+	}
+}
+
+func (e *EmojiRow) Schema() bigquery.Schema {
+	schema, _ := bigquery.InferSchema(e)
+	return schema
+}
+
+func (e *EmojiRow) TableMetadata() *bigquery.TableMetadata {
+	return &bigquery.TableMetadata{
+		Description: "emoji holds the custom emoji of the workspace. For field descriptions see the official " +
+			"documentation: https://api.slack.com/methods/emoji.list",
+		Schema: e.Schema(),
+	}
+}
+
+func (e *EmojiRow) InsertID(jobID uuid.UUID) string {
+	return strings.Join([]string{
+		jobID.String(),
+		e.Name,
+	}, "-")
+}
+
+// NewEmojiRows returns a row for each emoji, sorted by name.
+// The emoji are mapped from their name to their URL, or to the name of the emoji they are an alias for.
+func NewEmojiRows(snapshot Snapshot, emoji map[string]string) []Row {
+	names := make([]string, 0, len(emoji))
+	for name := range emoji {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := make([]Row, 0, len(emoji))
+	for _, name := range names {
+		row := &EmojiRow{Snapshot: snapshot}
+		row.UnmarshalSlackEmoji(name, emoji[name])
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (e *EmojiRow) UnmarshalSlackEmoji(name string, value string) {
+	e.Name = name
+	if strings.HasPrefix(value, emojiAliasPrefix) {
+		e.AliasFor = strings.TrimPrefix(value, emojiAliasPrefix)
+		return
+	}
+	e.URL = value
+}
@@ -12,6 +12,7 @@ import (
 // FilesRow follows the structure of the WebAPI. For field descriptions see the official
 // documentation: https://api.slack.com/types/file
 type FilesRow struct {
+	Snapshot
 	ID                 string     `bigquery:"id"`
 	Created            civil.Time `bigquery:"created"`
 	Name               string     `bigquery:"name"`
@@ -78,8 +79,12 @@ type ShareFileInfo struct {
 	TeamID          string   `bigquery:"team_id"`
 }
 
+func (f *FilesRow) TableName() string {
+	return "files"
+}
+
 func (f *FilesRow) TableID(date civil.Date) string {
-	return "files_" + strings.ReplaceAll(date.String(), "-", "")
+	return ShardedTableID(f.TableName(), date)
 }
 
 func (f *FilesRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
@@ -110,6 +115,18 @@ func (f *FilesRow) InsertID(jobID uuid.UUID) string {
 	}, "-")
 }
 
+// NewFilesRows returns a row for each slack.File.
+func NewFilesRows(snapshot Snapshot, files []slack.File) []Row {
+	rows := make([]Row, 0, len(files))
+	for _, file := range files {
+		file := file
+		row := &FilesRow{Snapshot: snapshot}
+		row.UnmarshalFile(&file)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 func (f *FilesRow) UnmarshalFile(sf *slack.File) {
 	if sf == nil {
 		*f = FilesRow{}
@@ -0,0 +1,106 @@
+package tables
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+)
+
+const (
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// JobRunsRow records a run of an import job.
+// A row is written when the job starts and another when it ends, the latest row of a job holds its final status.
+type JobRunsRow struct {
+	Snapshot
+	Status        string                 `bigquery:"status"`
+	StartTime     time.Time              `bigquery:"start_time"`
+	EndTime       bigquery.NullTimestamp `bigquery:"end_time"`
+	Error         string                 `bigquery:"error"`
+	SlackAPICalls []JobRunCount          `bigquery:"slack_api_calls"`
+	TableRows     []JobRunCount          `bigquery:"table_rows"`
+	TableErrors   []JobRunTableError     `bigquery:"table_errors"`
+	FilesWindow
+}
+
+var _ Row = &JobRunsRow{}
+
+// JobRunCount is a named count of a job run, e.g. the number of rows written to a table.
+type JobRunCount struct {
+	Name  string `bigquery:"name"`
+	Count int    `bigquery:"count"`
+}
+
+// JobRunTableError is the first of the errors that occurred while exporting a table, and the number of errors.
+type JobRunTableError struct {
+	Name  string `bigquery:"name"`
+	Count int    `bigquery:"count"`
+	Error string `bigquery:"error"`
+}
+
+// FilesWindow is the creation time window of the files exported by a job run that exports files incrementally.
+// FilesFrom is null when all files created before FilesTo were exported, and FilesRefreshedAt is the end of the
+// window of the latest run that exported all files.
+type FilesWindow struct {
+	FilesFrom        bigquery.NullTimestamp `bigquery:"files_from"`
+	FilesTo          bigquery.NullTimestamp `bigquery:"files_to"`
+	FilesRefreshedAt bigquery.NullTimestamp `bigquery:"files_refreshed_at"`
+}
+
+func (j *JobRunsRow) TableName() string {
+	return "job_runs"
+}
+
+// TableID returns the name of the table, since the job runs table is shared by all jobs.
+func (j *JobRunsRow) TableID(_ civil.Date) string {
+	return j.TableName()
+}
+
+func (j *JobRunsRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
+	return &bigquery.StructSaver{
+		Schema:   j.Schema(),
+		InsertID: j.InsertID(jobID),
+		Struct:   j,
+	}
+}
+
+func (j *JobRunsRow) Schema() bigquery.Schema {
+	schema, _ := bigquery.InferSchema(j)
+	return schema
+}
+
+func (j *JobRunsRow) TableMetadata() *bigquery.TableMetadata {
+	return &bigquery.TableMetadata{
+		Description: "job_runs records the runs of import jobs. The latest row of a job holds its final status.",
+		Schema:      j.Schema(),
+	}
+}
+
+// InsertID includes the start time, since a resumed job starts a new run with the same job ID.
+func (j *JobRunsRow) InsertID(jobID uuid.UUID) string {
+	return strings.Join([]string{
+		jobID.String(),
+		strconv.FormatInt(j.StartTime.UnixNano(), 10),
+		j.Status,
+	}, "-")
+}
+
+// NewJobRunCounts returns the counts sorted by name.
+func NewJobRunCounts(counts map[string]int) []JobRunCount {
+	result := make([]JobRunCount, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, JobRunCount{Name: name, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
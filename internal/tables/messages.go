@@ -0,0 +1,150 @@
+package tables
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+// MessagesRow follows the structure of the WebAPI. For field descriptions see the official
+// documentation: https://api.slack.com/events/message
+//
+// Thread replies are stored alongside the channel messages and reference their parent through thread_ts.
+type MessagesRow struct {
+	Snapshot
+	ChannelID       string    `bigquery:"channel_id"`
+	ChannelName     string    `bigquery:"channel_name"`
+	Timestamp       string    `bigquery:"ts"`
+	Created         time.Time `bigquery:"created"`
+	ThreadTimestamp string    `bigquery:"thread_ts"`
+	ParentUserID    string    `bigquery:"parent_user_id"`
+	ClientMsgID     string    `bigquery:"client_msg_id"`
+	Type            string    `bigquery:"type"`
+	SubType         string    `bigquery:"subtype"`
+	User            string    `bigquery:"user"`
+	BotID           string    `bigquery:"bot_id"`
+	Username        string    `bigquery:"username"`
+	Team            string    `bigquery:"team"`
+	Text            string    `bigquery:"text"`
+	Edited          Edited    `bigquery:"edited"`
+	ReplyCount      int       `bigquery:"reply_count"`
+	LatestReply     string    `bigquery:"latest_reply"`
+	Files           []string  `bigquery:"files"`
+}
+
+var _ Row = &MessagesRow{}
+
+type Edited struct {
+	User      string `bigquery:"user"`
+	Timestamp string `bigquery:"ts"`
+}
+
+func (m *MessagesRow) TableName() string {
+	return "messages"
+}
+
+func (m *MessagesRow) TableID(date civil.Date) string {
+	return ShardedTableID(m.TableName(), date)
+}
+
+func (m *MessagesRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
+	return &bigquery.StructSaver{
+		Schema:   m.Schema(),
+		InsertID: m.InsertID(jobID),
+		Struct:   m,
+	}
+}
+
+func (m *MessagesRow) Schema() bigquery.Schema {
+	schema, _ := bigquery.InferSchema(m)
+	return schema
+}
+
+func (m *MessagesRow) TableMetadata() *bigquery.TableMetadata {
+	return &bigquery.TableMetadata{
+		Description: "messages follows the structure of the WebAPI. For field descriptions see the official " +
+			"documentation: https://api.slack.com/events/message",
+		Schema: m.Schema(),
+	}
+}
+
+func (m *MessagesRow) InsertID(jobID uuid.UUID) string {
+	return strings.Join([]string{
+		jobID.String(),
+		m.ChannelID,
+		m.Timestamp,
+	}, "-")
+}
+
+// NewMessagesRows returns a row for each slack.Message posted in a slack.Channel.
+func NewMessagesRows(snapshot Snapshot, channel *slack.Channel, messages []slack.Message) []Row {
+	rows := make([]Row, 0, len(messages))
+	for _, message := range messages {
+		message := message
+		row := &MessagesRow{
+			Snapshot:    snapshot,
+			ChannelID:   channel.ID,
+			ChannelName: channel.Name,
+		}
+		row.UnmarshalSlackMessage(&message)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (m *MessagesRow) UnmarshalSlackMessage(sm *slack.Message) {
+	if sm == nil {
+		*m = MessagesRow{}
+		return
+	}
+	m.Timestamp = sm.Timestamp
+	m.Created = ParseTimestamp(sm.Timestamp)
+	m.ThreadTimestamp = sm.ThreadTimestamp
+	m.ParentUserID = sm.ParentUserId
+	m.ClientMsgID = sm.ClientMsgID
+	m.Type = sm.Type
+	m.SubType = sm.SubType
+	m.User = sm.User
+	m.BotID = sm.BotID
+	m.Username = sm.Username
+	m.Team = sm.Team
+	m.Text = sm.Text
+	m.Edited.UnmarshalEdited(sm.Edited)
+	m.ReplyCount = sm.ReplyCount
+	m.LatestReply = sm.LatestReply
+	m.Files = make([]string, 0, len(sm.Files))
+	for _, file := range sm.Files {
+		m.Files = append(m.Files, file.ID)
+	}
+}
+
+func (e *Edited) UnmarshalEdited(se *slack.Edited) {
+	if se == nil {
+		*e = Edited{}
+		return
+	}
+	e.User = se.User
+	e.Timestamp = se.Timestamp
+}
+
+// ParseTimestamp parses a Slack message timestamp on the form "1355517523.000005".
+// An invalid timestamp results in the zero time.
+func ParseTimestamp(ts string) time.Time {
+	parts := strings.SplitN(ts, ".", 2)
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	var micros int64
+	if len(parts) == 2 {
+		if micros, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return time.Time{}
+		}
+	}
+	return time.Unix(seconds, micros*int64(time.Microsecond)).UTC()
+}
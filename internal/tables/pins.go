@@ -0,0 +1,109 @@
+package tables
+
+import (
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+// PinsRow holds an item pinned to a channel, e.g. a message or a file. For field descriptions see the official
+// documentation: https://api.slack.com/methods/pins.list
+//
+// The creator and created time are who pinned the item and when, rather than those of the pinned item.
+type PinsRow struct {
+	Snapshot
+	ChannelID   string    `bigquery:"channel_id"`
+	ChannelName string    `bigquery:"channel_name"`
+	Type        string    `bigquery:"type"`
+	Timestamp   string    `bigquery:"ts"`
+	FileID      string    `bigquery:"file_id"`
+	Title       string    `bigquery:"title"`
+	Text        string    `bigquery:"text"`
+	Link        string    `bigquery:"link"`
+	Creator     string    `bigquery:"creator"`
+	Created     time.Time `bigquery:"created"`
+}
+
+var _ Row = &PinsRow{}
+
+// PinnedItem is an item pinned to a channel, along with who pinned it and when, which slack.Item leaves out.
+type PinnedItem struct {
+	slack.Item
+	Created   slack.JSONTime `json:"created"`
+	CreatedBy string         `json:"created_by"`
+}
+
+func (p *PinsRow) TableName() string {
+	return "pins"
+}
+
+func (p *PinsRow) TableID(date civil.Date) string {
+	return ShardedTableID(p.TableName(), date)
+}
+
+func (p *PinsRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
+	return &bigquery.StructSaver{
+		Schema:   p.Schema(),
+		InsertID: p.InsertID(jobID),
+		Struct:   p,
+	}
+}
+
+func (p *PinsRow) Schema() bigquery.Schema {
+	schema, _ := bigquery.InferSchema(p)
+	return schema
+}
+
+func (p *PinsRow) TableMetadata() *bigquery.TableMetadata {
+	return &bigquery.TableMetadata{
+		Description: "pins holds the items pinned to channels. For field descriptions see the official " +
+			"documentation: https://api.slack.com/methods/pins.list",
+		Schema: p.Schema(),
+	}
+}
+
+func (p *PinsRow) InsertID(jobID uuid.UUID) string {
+	return strings.Join([]string{
+		jobID.String(),
+		p.ChannelID,
+		p.Type,
+		p.Timestamp,
+		p.FileID,
+	}, "-")
+}
+
+// NewPinsRows returns a row for each item pinned to a channel.
+func NewPinsRows(snapshot Snapshot, channel *slack.Channel, items []PinnedItem) []Row {
+	rows := make([]Row, 0, len(items))
+	for _, item := range items {
+		item := item
+		row := &PinsRow{
+			Snapshot:    snapshot,
+			ChannelID:   channel.ID,
+			ChannelName: channel.Name,
+		}
+		row.UnmarshalSlackItem(&item)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (p *PinsRow) UnmarshalSlackItem(si *PinnedItem) {
+	p.Type = si.Type
+	p.Creator = si.CreatedBy
+	p.Created = si.Created.Time().UTC()
+	if si.Message != nil {
+		p.Timestamp = si.Message.Timestamp
+		p.Text = si.Message.Text
+		p.Link = si.Message.Permalink
+	}
+	if si.File != nil {
+		p.FileID = si.File.ID
+		p.Title = si.File.Title
+		p.Link = si.File.Permalink
+	}
+}
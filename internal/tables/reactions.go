@@ -0,0 +1,91 @@
+package tables
+
+import (
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+// ReactionsRow holds a reaction of a user to a message, one row per message, emoji and reacting user.
+// Reactions are taken from the exported messages. For field descriptions see the official documentation:
+// https://api.slack.com/events/message
+//
+// Slack truncates the users of each reaction in message payloads, so a message and emoji may have fewer rows than
+// users that reacted. Count is the total number of users that reacted with the emoji, on every row of the reaction.
+type ReactionsRow struct {
+	Snapshot
+	ChannelID        string `bigquery:"channel_id"`
+	ChannelName      string `bigquery:"channel_name"`
+	MessageTimestamp string `bigquery:"message_ts"`
+	ThreadTimestamp  string `bigquery:"thread_ts"`
+	Name             string `bigquery:"name"`
+	User             string `bigquery:"user"`
+	Count            int    `bigquery:"count"`
+}
+
+var _ Row = &ReactionsRow{}
+
+func (r *ReactionsRow) TableName() string {
+	return "reactions"
+}
+
+func (r *ReactionsRow) TableID(date civil.Date) string {
+	return ShardedTableID(r.TableName(), date)
+}
+
+func (r *ReactionsRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
+	return &bigquery.StructSaver{
+		Schema:   r.Schema(),
+		InsertID: r.InsertID(jobID),
+		Struct:   r,
+	}
+}
+
+func (r *ReactionsRow) Schema() bigquery.Schema {
+	schema, _ := bigquery.InferSchema(r)
+	return schema
+}
+
+func (r *ReactionsRow) TableMetadata() *bigquery.TableMetadata {
+	return &bigquery.TableMetadata{
+		Description: "reactions holds the reactions of users to messages, one row per message, emoji and user. " +
+			"The users of a reaction may be truncated, while count is the total number of users of the reaction. " +
+			"For field descriptions see the official documentation: https://api.slack.com/events/message",
+		Schema: r.Schema(),
+	}
+}
+
+func (r *ReactionsRow) InsertID(jobID uuid.UUID) string {
+	return strings.Join([]string{
+		jobID.String(),
+		r.ChannelID,
+		r.MessageTimestamp,
+		r.Name,
+		r.User,
+	}, "-")
+}
+
+// NewReactionsRows returns a row for each user that reacted with each emoji to each slack.Message posted in a channel.
+func NewReactionsRows(snapshot Snapshot, channel *slack.Channel, messages []slack.Message) []Row {
+	var rows []Row
+	for _, message := range messages {
+		for _, reaction := range message.Reactions {
+			for _, user := range reaction.Users {
+				rows = append(rows, &ReactionsRow{
+					Snapshot:         snapshot,
+					ChannelID:        channel.ID,
+					ChannelName:      channel.Name,
+					MessageTimestamp: message.Timestamp,
+					ThreadTimestamp:  message.ThreadTimestamp,
+					Name:             reaction.Name,
+					User:             user,
+					Count:            reaction.Count,
+				})
+			}
+		}
+	}
+	return rows
+}
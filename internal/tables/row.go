@@ -1,13 +1,45 @@
 package tables
 
 import (
+	"strings"
+
 	"cloud.google.com/go/bigquery"
 	"cloud.google.com/go/civil"
 	"github.com/google/uuid"
 )
 
 type Row interface {
+	TableName() string
 	TableID(civil.Date) string
 	TableMetadata() *bigquery.TableMetadata
 	ValueSaver(uuid.UUID) bigquery.ValueSaver
 }
+
+// AllRows returns a row of each table type.
+func AllRows() []Row {
+	return []Row{
+		&TeamRow{},
+		&UsersRow{},
+		&UserProfileFieldsRow{},
+		&UserGroupsRow{},
+		&ChannelsRow{},
+		&ChannelMembersRow{},
+		&FilesRow{},
+		&MessagesRow{},
+		&ReactionsRow{},
+		&PinsRow{},
+		&BookmarksRow{},
+		&DirectConversationsRow{},
+		&EmojiRow{},
+	}
+}
+
+// ShardedTableID returns the ID of the table holding the snapshot of a table for a date, e.g. "users_20221017".
+func ShardedTableID(tableName string, date civil.Date) string {
+	return tableName + "_" + PartitionID(date)
+}
+
+// PartitionID returns the ID of the daily partition for a date, e.g. "20221017".
+func PartitionID(date civil.Date) string {
+	return strings.ReplaceAll(date.String(), "-", "")
+}
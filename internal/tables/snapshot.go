@@ -0,0 +1,15 @@
+package tables
+
+import (
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// Snapshot holds the columns that every table has, identifying the job and snapshot that produced a row.
+type Snapshot struct {
+	Org          string     `bigquery:"org"`
+	JobID        string     `bigquery:"job_id"`
+	SnapshotDate civil.Date `bigquery:"snapshot_date"`
+	ExportedAt   time.Time  `bigquery:"exported_at"`
+}
@@ -0,0 +1,115 @@
+package tables
+
+import (
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+// teamIconKeys are the keys of the team icon URLs returned by team.info, from the largest to the smallest.
+var teamIconKeys = []string{"image_original", "image_230", "image_132", "image_102", "image_88", "image_68"}
+
+// TeamRow describes the workspace that the data is exported from, along with the definitions of its custom profile
+// fields. For field descriptions see the official documentation: https://api.slack.com/methods/team.info and
+// https://api.slack.com/methods/team.profile.get
+//
+// The profile fields are only exported along with the user profile fields table, which needs the same scope.
+type TeamRow struct {
+	Snapshot
+	ID            string             `bigquery:"id"`
+	Name          string             `bigquery:"name"`
+	Domain        string             `bigquery:"domain"`
+	EmailDomain   string             `bigquery:"email_domain"`
+	Icon          string             `bigquery:"icon"`
+	EnterpriseID  string             `bigquery:"enterprise_id"`
+	ProfileFields []TeamProfileField `bigquery:"profile_fields"`
+}
+
+var _ Row = &TeamRow{}
+
+// TeamProfileField is the definition of a custom profile field of a workspace.
+type TeamProfileField struct {
+	ID             string   `bigquery:"id"`
+	Ordering       int      `bigquery:"ordering"`
+	Label          string   `bigquery:"label"`
+	Hint           string   `bigquery:"hint"`
+	Type           string   `bigquery:"type"`
+	PossibleValues []string `bigquery:"possible_values"`
+	IsHidden       bool     `bigquery:"is_hidden"`
+}
+
+func (t *TeamRow) TableName() string {
+	return "team"
+}
+
+func (t *TeamRow) TableID(date civil.Date) string {
+	return ShardedTableID(t.TableName(), date)
+}
+
+func (t *TeamRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
+	return &bigquery.StructSaver{
+		Schema:   t.Schema(),
+		InsertID: t.InsertID(jobID),
+		Struct:   t,
+	}
+}
+
+func (t *TeamRow) Schema() bigquery.Schema {
+	schema, _ := bigquery.InferSchema(t)
+	return schema
+}
+
+func (t *TeamRow) TableMetadata() *bigquery.TableMetadata {
+	return &bigquery.TableMetadata{
+		Description: "team describes the workspace that the data is exported from. For field descriptions see the " +
+			"official documentation: https://api.slack.com/methods/team.info",
+		Schema: t.Schema(),
+	}
+}
+
+func (t *TeamRow) InsertID(jobID uuid.UUID) string {
+	return strings.Join([]string{
+		jobID.String(),
+		t.ID,
+	}, "-")
+}
+
+// NewTeamRows returns a row for a workspace, its custom profile field definitions and the ID of its enterprise
+// organization, which is empty for workspaces that are not part of an Enterprise Grid organization.
+func NewTeamRows(snapshot Snapshot, team *slack.TeamInfo, profile *slack.TeamProfile, enterpriseID string) []Row {
+	row := &TeamRow{Snapshot: snapshot, EnterpriseID: enterpriseID}
+	row.UnmarshalSlackTeam(team, profile)
+	return []Row{row}
+}
+
+func (t *TeamRow) UnmarshalSlackTeam(st *slack.TeamInfo, sp *slack.TeamProfile) {
+	if st != nil {
+		t.ID = st.ID
+		t.Name = st.Name
+		t.Domain = st.Domain
+		t.EmailDomain = st.EmailDomain
+		for _, key := range teamIconKeys {
+			if icon, ok := st.Icon[key].(string); ok && icon != "" {
+				t.Icon = icon
+				break
+			}
+		}
+	}
+	if sp != nil {
+		t.ProfileFields = make([]TeamProfileField, 0, len(sp.Fields))
+		for _, field := range sp.Fields {
+			t.ProfileFields = append(t.ProfileFields, TeamProfileField{
+				ID:             field.ID,
+				Ordering:       field.Ordering,
+				Label:          field.Label,
+				Hint:           field.Hint,
+				Type:           field.Type,
+				PossibleValues: field.PossibleValues,
+				IsHidden:       field.IsHidden,
+			})
+		}
+	}
+}
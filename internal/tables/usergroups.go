@@ -12,7 +12,7 @@ import (
 // UserGroupsRow follows the structure of the WebAPI. For field descriptions see the official
 // documentation: https://api.slack.com/types/usergroup
 type UserGroupsRow struct {
-	Org         string         `bigquery:"org"`
+	Snapshot
 	ID          string         `bigquery:"id"`
 	TeamID      string         `bigquery:"team_id"`
 	IsUserGroup bool           `bigquery:"is_usergroup"`
@@ -38,8 +38,12 @@ type UserGroupPrefs struct {
 	Groups   []string `bigquery:"groups"`
 }
 
+func (u *UserGroupsRow) TableName() string {
+	return "usergroups"
+}
+
 func (u *UserGroupsRow) TableID(date civil.Date) string {
-	return "usergroups_" + strings.ReplaceAll(date.String(), "-", "")
+	return ShardedTableID(u.TableName(), date)
 }
 
 func (u *UserGroupsRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
@@ -70,6 +74,18 @@ func (u *UserGroupsRow) InsertID(jobID uuid.UUID) string {
 	}, "-")
 }
 
+// NewUserGroupsRows returns a row for each slack.UserGroup.
+func NewUserGroupsRows(snapshot Snapshot, usergroups []slack.UserGroup) []Row {
+	rows := make([]Row, 0, len(usergroups))
+	for _, usergroup := range usergroups {
+		usergroup := usergroup
+		row := &UserGroupsRow{Snapshot: snapshot}
+		row.UnmarshalSlackUserGroup(&usergroup)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 func (u *UserGroupsRow) UnmarshalSlackUserGroup(su *slack.UserGroup) {
 	if su == nil {
 		*u = UserGroupsRow{}
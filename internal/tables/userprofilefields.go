@@ -0,0 +1,103 @@
+package tables
+
+import (
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+// UserProfileFieldsRow holds the value of a custom profile field of a user, along with the definition of the field.
+// For field descriptions see the official documentation: https://api.slack.com/methods/users.profile.get and
+// https://api.slack.com/methods/team.profile.get
+type UserProfileFieldsRow struct {
+	Snapshot
+	UserID  string `bigquery:"user_id"`
+	FieldID string `bigquery:"field_id"`
+	Label   string `bigquery:"label"`
+	Type    string `bigquery:"type"`
+	Value   string `bigquery:"value"`
+	Alt     string `bigquery:"alt"`
+}
+
+var _ Row = &UserProfileFieldsRow{}
+
+func (u *UserProfileFieldsRow) TableName() string {
+	return "user_profile_fields"
+}
+
+func (u *UserProfileFieldsRow) TableID(date civil.Date) string {
+	return ShardedTableID(u.TableName(), date)
+}
+
+func (u *UserProfileFieldsRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
+	return &bigquery.StructSaver{
+		Schema:   u.Schema(),
+		InsertID: u.InsertID(jobID),
+		Struct:   u,
+	}
+}
+
+func (u *UserProfileFieldsRow) Schema() bigquery.Schema {
+	schema, _ := bigquery.InferSchema(u)
+	return schema
+}
+
+func (u *UserProfileFieldsRow) TableMetadata() *bigquery.TableMetadata {
+	return &bigquery.TableMetadata{
+		Description: "user_profile_fields holds the values of the custom profile fields of users. For field " +
+			"descriptions see the official documentation: https://api.slack.com/methods/users.profile.get",
+		Schema: u.Schema(),
+	}
+}
+
+func (u *UserProfileFieldsRow) InsertID(jobID uuid.UUID) string {
+	return strings.Join([]string{
+		jobID.String(),
+		u.UserID,
+		u.FieldID,
+	}, "-")
+}
+
+// NewUserProfileFieldsRows returns a row for each custom field of the profile of a user, sorted by field ID.
+// The label and type of each field are taken from the field definitions of the team profile.
+func NewUserProfileFieldsRows(
+	snapshot Snapshot,
+	userID string,
+	profile *slack.UserProfile,
+	teamProfile *slack.TeamProfile,
+) []Row {
+	definitions := make(map[string]slack.TeamProfileField)
+	if teamProfile != nil {
+		for _, field := range teamProfile.Fields {
+			definitions[field.ID] = field
+		}
+	}
+	fields := profile.FieldsMap()
+	fieldIDs := make([]string, 0, len(fields))
+	for fieldID := range fields {
+		fieldIDs = append(fieldIDs, fieldID)
+	}
+	sort.Strings(fieldIDs)
+	rows := make([]Row, 0, len(fields))
+	for _, fieldID := range fieldIDs {
+		field, definition := fields[fieldID], definitions[fieldID]
+		label := definition.Label
+		if label == "" {
+			label = field.Label
+		}
+		rows = append(rows, &UserProfileFieldsRow{
+			Snapshot: snapshot,
+			UserID:   userID,
+			FieldID:  fieldID,
+			Label:    label,
+			Type:     definition.Type,
+			Value:    field.Value,
+			Alt:      field.Alt,
+		})
+	}
+	return rows
+}
@@ -12,7 +12,7 @@ import (
 // UsersRow follows the structure of the WebAPI. For field descriptions see the official
 // documentation: https://api.slack.com/types/user
 type UsersRow struct {
-	Org               string      `bigquery:"org"`
+	Snapshot
 	ID                string      `bigquery:"id"`
 	TeamID            string      `bigquery:"team_id"`
 	Deleted           bool        `bigquery:"deleted"`
@@ -57,8 +57,12 @@ type UserProfile struct {
 	Team                  string `bigquery:"team"`
 }
 
+func (u *UsersRow) TableName() string {
+	return "users"
+}
+
 func (u *UsersRow) TableID(date civil.Date) string {
-	return "users_" + strings.ReplaceAll(date.String(), "-", "")
+	return ShardedTableID(u.TableName(), date)
 }
 
 func (u *UsersRow) ValueSaver(jobID uuid.UUID) bigquery.ValueSaver {
@@ -89,6 +93,18 @@ func (u *UsersRow) InsertID(jobID uuid.UUID) string {
 	}, "-")
 }
 
+// NewUsersRows returns a row for each slack.User.
+func NewUsersRows(snapshot Snapshot, users []slack.User) []Row {
+	rows := make([]Row, 0, len(users))
+	for _, user := range users {
+		user := user
+		row := &UsersRow{Snapshot: snapshot}
+		row.UnmarshalSlackUser(&user)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 func (u *UsersRow) UnmarshalSlackUser(su *slack.User) {
 	if su == nil {
 		*u = UsersRow{}
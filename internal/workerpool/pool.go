@@ -0,0 +1,114 @@
+// Package workerpool provides a bounded pool of workers for running tasks concurrently.
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// Pool runs tasks concurrently on a bounded number of workers.
+//
+// The errors of the tasks are aggregated and returned by Wait. The first fatal error cancels the context of the pool,
+// so that the remaining tasks stop early. Errors wrapped with NonFatal are aggregated without cancelling the pool.
+type Pool struct {
+	parent  context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	err     error
+}
+
+// New returns a new pool running at most size tasks at a time, and a context that is cancelled on the first
+// fatal error. A size less than 1 is treated as 1.
+func New(ctx context.Context, size int) (*Pool, context.Context) {
+	if size < 1 {
+		size = 1
+	}
+	poolCtx, cancel := context.WithCancel(ctx)
+	return &Pool{
+		parent:  ctx,
+		ctx:     poolCtx,
+		cancel:  cancel,
+		workers: make(chan struct{}, size),
+	}, poolCtx
+}
+
+// Go runs a task on the next available worker, blocking while all workers are busy.
+// Tasks are not started after the pool has been cancelled.
+func (p *Pool) Go(task func(context.Context) error) {
+	if p.ctx.Err() != nil {
+		return
+	}
+	select {
+	case p.workers <- struct{}{}:
+	case <-p.ctx.Done():
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.workers }()
+		if err := task(p.ctx); err != nil {
+			p.fail(err)
+		}
+	}()
+}
+
+// Cancel stops the pool, e.g. when the producer of tasks fails.
+func (p *Pool) Cancel() {
+	p.cancel()
+}
+
+// Wait blocks until all started tasks are done, and returns their aggregated errors.
+// Errors caused by the cancellation of the pool are left out. When the parent context of the pool was cancelled, its
+// error is returned if no task failed, since tasks may have been stopped or never started.
+func (p *Pool) Wait() error {
+	p.wg.Wait()
+	p.cancel()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		return p.parent.Err()
+	}
+	return p.err
+}
+
+func (p *Pool) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+	var nonFatal *nonFatalError
+	if errors.As(err, &nonFatal) {
+		p.err = multierr.Append(p.err, nonFatal.err)
+		return
+	}
+	p.err = multierr.Append(p.err, err)
+	p.cancel()
+}
+
+// NonFatal wraps an error that is aggregated by the pool without cancelling the remaining tasks.
+func NonFatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &nonFatalError{err: err}
+}
+
+type nonFatalError struct {
+	err error
+}
+
+func (e *nonFatalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nonFatalError) Unwrap() error {
+	return e.err
+}
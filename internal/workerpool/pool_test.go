@@ -0,0 +1,56 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPool_Wait(t *testing.T) {
+	errTask := errors.New("task failed")
+	for _, tt := range []struct {
+		name    string
+		cancel  bool
+		task    func(context.Context) error
+		wantErr error
+	}{
+		{
+			name: "succeeded",
+			task: func(context.Context) error { return nil },
+		},
+		{
+			name:    "failed",
+			task:    func(context.Context) error { return errTask },
+			wantErr: errTask,
+		},
+		{
+			name:    "non-fatal",
+			task:    func(context.Context) error { return NonFatal(errTask) },
+			wantErr: errTask,
+		},
+		{
+			name:    "parent cancelled",
+			cancel:  true,
+			task:    func(ctx context.Context) error { return ctx.Err() },
+			wantErr: context.Canceled,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			pool, _ := New(ctx, 2)
+			pool.Go(func(ctx context.Context) error {
+				if tt.cancel {
+					cancel()
+				}
+				return tt.task(ctx)
+			})
+			// Tasks are not started after the parent is cancelled, which must not be mistaken for success.
+			pool.Go(tt.task)
+			if err := pool.Wait(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
@@ -43,5 +44,10 @@ func main() {
 	defer cleanupApp()
 	if err := app.Run(ctx); err != nil {
 		logger.Error("failed to run", zap.Error(err))
+		// Exit non-zero, running the deferred cleanups first since os.Exit skips them.
+		cleanupApp()
+		cleanupLogger()
+		cancel()
+		os.Exit(1)
 	}
 }